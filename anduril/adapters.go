@@ -2,6 +2,8 @@ package anduril
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/cicovic-andrija/libgo/https"
 )
@@ -42,3 +44,26 @@ func (s *WebServer) FindAndReadLockRevision(objectType ObjectType) https.Adapter
 		})
 	}
 }
+
+// RouteSuffix is an https.Adapter generator used to make adapters that divert requests
+// with URL path ending with suffix to the handler h, after removing the suffix from the
+// URL path. All other requests are passed down the chain unchanged.
+func RouteSuffix(suffix string, h http.Handler) https.Adapter {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if path := strings.TrimSuffix(r.URL.Path, suffix); path != r.URL.Path {
+				r2 := new(http.Request)
+				*r2 = *r
+				r2.URL = new(url.URL)
+				*r2.URL = *r.URL
+				r2.URL.Path = path
+				r2.URL.RawPath = ""
+				h.ServeHTTP(w, r2)
+				return
+			}
+
+			// Call the next handler in the chain.
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package anduril
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cicovic-andrija/libgo/https"
+)
+
+// Administration endpoints, enabled only if settings.admin_token is set. Requests are
+// authenticated with the token sent as a bearer token in the Authorization header.
+
+const (
+	// URL path of the list of retained revisions.
+	AdminRevisionsPath = "/admin/revisions"
+	// URL path of the action which pins the served revision to a retained revision.
+	AdminPinRevisionPath = "/admin/revisions/pin"
+	// URL path of the action which removes the pin.
+	AdminUnpinRevisionPath = "/admin/revisions/unpin"
+)
+
+type revisionInfo struct {
+	Hash    string           `json:"hash"`
+	Sources []SourceRevision `json:"sources"`
+	BuiltAt time.Time        `json:"built_at"`
+	Served  bool             `json:"served"`
+	Pinned  bool             `json:"pinned"`
+}
+
+// RequireAdminToken is an https.Adapter used to reject requests which do not
+// carry the configured admin token.
+func (s *WebServer) RequireAdminToken(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(s.settings.AdminToken)) != 1 {
+			s.warn("admin: rejected request for %s from %s: invalid token", r.URL.Path, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		s.trace(AdminTag, "accepted: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+
+		// Call the next handler in the chain.
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (s *WebServer) AdminRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	s.writeRevisionList(w)
+}
+
+func (s *WebServer) AdminPinRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	hash := r.FormValue("hash")
+	switch err := s.pinRevision(hash); err {
+	case nil:
+		s.trace(AdminTag, "served revision pinned to %s by %s", hash, r.RemoteAddr)
+		s.writeRevisionList(w)
+	case ErrRevisionNotRetained:
+		http.Error(w, "revision not retained: "+hash, http.StatusNotFound)
+	default:
+		s.warn("admin: failed to pin revision %s: %v", hash, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (s *WebServer) AdminUnpinRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := s.unpinRevision(); err != nil {
+		s.warn("admin: failed to unpin revision: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	s.trace(AdminTag, "revision pin removed by %s", r.RemoteAddr)
+	s.writeRevisionList(w)
+}
+
+// writeRevisionList writes the list of retained revisions, newest first.
+func (s *WebServer) writeRevisionList(w http.ResponseWriter) {
+	s.revisionLock.RLock()
+	revisions := make([]revisionInfo, 0, len(s.revisions))
+	for _, revision := range s.revisions {
+		revisions = append(revisions, revisionInfo{
+			Hash:    revision.Hash,
+			Sources: revision.Sources,
+			BuiltAt: revision.BuiltAt,
+			Served:  revision == s.latestRevision,
+			Pinned:  revision.Hash == s.pinnedHash,
+		})
+	}
+	s.revisionLock.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(revisions); err != nil {
+		s.warn("admin: failed to encode list of revisions: %v", err)
+	}
+}
+
+// adminHandlers returns the administration endpoints, which accept requests other
+// than GET, keyed by URL path. Requests for the endpoints are always traced to the
+// primary log, even if logging of other requests is disabled.
+func (s *WebServer) adminHandlers() map[string]http.Handler {
+	handlers := make(map[string]http.Handler)
+	for path, handler := range map[string]http.HandlerFunc{
+		AdminRevisionsPath:     s.AdminRevisionsHandler,
+		AdminPinRevisionPath:   s.AdminPinRevisionHandler,
+		AdminUnpinRevisionPath: s.AdminUnpinRevisionHandler,
+	} {
+		handlers[path] = https.Adapt(handler, s.RequireAdminToken)
+	}
+	return handlers
+}
+
+// registerAdminHandlers registers the administration endpoints if the admin token is configured.
+func (s *WebServer) registerAdminHandlers() {
+	if s.settings.AdminToken == "" {
+		return
+	}
+
+	for path, handler := range s.adminHandlers() {
+		s.httpsServer.Handle(path, handler)
+	}
+}
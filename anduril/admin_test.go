@@ -0,0 +1,152 @@
+package anduril_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+)
+
+const testAdminToken = "secret-token"
+
+type testRevisionInfo struct {
+	Hash   string `json:"hash"`
+	Served bool   `json:"served"`
+	Pinned bool   `json:"pinned"`
+}
+
+func newAdminTestServer(t *testing.T, wd string, retained int) *anduril.WebServer {
+	return newTestServer(t, wd, func(config *anduril.Config) {
+		config.Settings.AdminToken = testAdminToken
+		config.Settings.RetainedRevisions = retained
+	})
+}
+
+// serveAdmin serves the request with the admin handler, authenticated with the token.
+func serveAdmin(server *anduril.WebServer, handler http.HandlerFunc, method string, token string, form url.Values) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, "https://localhost/admin", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	server.RequireAdminToken(handler).ServeHTTP(recorder, request)
+	return recorder
+}
+
+// retainedRevisions returns the list of retained revisions reported by the admin endpoint.
+func retainedRevisions(t *testing.T, server *anduril.WebServer) []testRevisionInfo {
+	t.Helper()
+
+	response := serveAdmin(server, server.AdminRevisionsHandler, http.MethodGet, testAdminToken, nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("list revisions: expected: %d found: %d", http.StatusOK, response.Code)
+	}
+	revisions := []testRevisionInfo{}
+	if err := json.NewDecoder(response.Body).Decode(&revisions); err != nil {
+		t.Fatalf("list revisions: %v", err)
+	}
+	return revisions
+}
+
+func pinRevision(t *testing.T, server *anduril.WebServer, hash string) {
+	t.Helper()
+
+	response := serveAdmin(server, server.AdminPinRevisionHandler, http.MethodPost, testAdminToken, url.Values{"hash": {hash}})
+	if response.Code != http.StatusOK {
+		t.Fatalf("pin revision %s: expected: %d found: %d", hash, http.StatusOK, response.Code)
+	}
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	server := newAdminTestServer(t, t.TempDir(), 0)
+
+	for _, token := range []string{"", "wrong-token"} {
+		response := serveAdmin(server, server.AdminRevisionsHandler, http.MethodGet, token, nil)
+		if response.Code != http.StatusUnauthorized {
+			t.Fatalf("token %q: expected: %d found: %d", token, http.StatusUnauthorized, response.Code)
+		}
+		if response.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Fatalf("token %q: WWW-Authenticate header not set", token)
+		}
+	}
+
+	response := serveAdmin(server, server.AdminRevisionsHandler, http.MethodGet, testAdminToken, nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected: %d found: %d", http.StatusOK, response.Code)
+	}
+}
+
+func TestAdminPinRevisionNotRetained(t *testing.T) {
+	server := newAdminTestServer(t, t.TempDir(), 0)
+	server.PublishRevision(newTestRevision("a"))
+
+	response := serveAdmin(server, server.AdminPinRevisionHandler, http.MethodPost, testAdminToken, url.Values{"hash": {"b"}})
+	if response.Code != http.StatusNotFound {
+		t.Fatalf("expected: %d found: %d", http.StatusNotFound, response.Code)
+	}
+	if served := server.LatestRevision().Hash; served != "a" {
+		t.Fatalf("served revision: expected: a found: %s", served)
+	}
+}
+
+func TestAdminMethodNotAllowed(t *testing.T) {
+	server := newAdminTestServer(t, t.TempDir(), 0)
+
+	for _, test := range []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		allow   string
+	}{
+		{"revisions", server.AdminRevisionsHandler, http.MethodPost, http.MethodGet},
+		{"pin", server.AdminPinRevisionHandler, http.MethodGet, http.MethodPost},
+		{"unpin", server.AdminUnpinRevisionHandler, http.MethodGet, http.MethodPost},
+	} {
+		response := serveAdmin(server, test.handler, test.method, testAdminToken, nil)
+		if response.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("%s: expected: %d found: %d", test.name, http.StatusMethodNotAllowed, response.Code)
+		}
+		if allow := response.Header().Get("Allow"); allow != test.allow {
+			t.Fatalf("%s: Allow: expected: %s found: %s", test.name, test.allow, allow)
+		}
+	}
+}
+
+func TestAdminRegisteredHandlersWithoutRequestLog(t *testing.T) {
+	server := newTestServer(t, t.TempDir(), func(config *anduril.Config) {
+		config.HTTPS.LogRequests = false
+		config.Settings.AdminToken = testAdminToken
+	})
+	server.PublishRevision(newTestRevision("a"))
+
+	for _, test := range []struct {
+		path   string
+		method string
+		token  string
+		form   url.Values
+		code   int
+	}{
+		{anduril.AdminRevisionsPath, http.MethodGet, testAdminToken, nil, http.StatusOK},
+		{anduril.AdminRevisionsPath, http.MethodGet, "wrong-token", nil, http.StatusUnauthorized},
+		{anduril.AdminPinRevisionPath, http.MethodPost, testAdminToken, url.Values{"hash": {"a"}}, http.StatusOK},
+		{anduril.AdminUnpinRevisionPath, http.MethodPost, testAdminToken, nil, http.StatusOK},
+	} {
+		handler := server.AdminHandler(test.path)
+		if handler == nil {
+			t.Fatalf("%s: handler not registered", test.path)
+		}
+		request := httptest.NewRequest(test.method, "https://localhost"+test.path, strings.NewReader(test.form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "Bearer "+test.token)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != test.code {
+			t.Fatalf("%s %s: expected: %d found: %d", test.method, test.path, test.code, recorder.Code)
+		}
+	}
+}
@@ -1,11 +1,18 @@
 package anduril
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 
@@ -18,52 +25,97 @@ import (
 
 const MarkdownExtension = ".md"
 
+// Suffix of files with compiled output which is still being written.
+const PartialFileSuffix = ".partial"
+
+// Sequence number of conversions, which makes names of temporary files unique.
+var conversionSequence atomic.Uint64
+
 // Tags which trigger special behavior or different way of rendering.
 const (
 	PrivateArticleTag = "private"
 )
 
 type Article struct {
-	Title        string    `yaml:"title"`
-	Type         string    `yaml:"type"`
-	Comment      string    `yaml:"comment"`
-	Tags         []string  `yaml:"tags"`
-	Created      string    `yaml:"created"`
-	CreatedTime  time.Time `yaml:"-"`
-	Modified     string    `yaml:"modified"`
-	ModifiedTime time.Time `yaml:"-"`
-	File         string    `yaml:"-"`
-	Key          string    `yaml:"-"`
-}
-
-func (s *WebServer) processRevision(revision *Revision) error {
-	if err := fs.EnumerateDirectory(
-		revision.ContainerPath,
-		func(fileName string) {
+	Title        string    `yaml:"title" json:"title"`
+	Type         string    `yaml:"type" json:"type"`
+	Comment      string    `yaml:"comment" json:"comment"`
+	Tags         []string  `yaml:"tags" json:"tags"`
+	Created      string    `yaml:"created" json:"created"`
+	CreatedTime  time.Time `yaml:"-" json:"created_time"`
+	Modified     string    `yaml:"modified" json:"modified"`
+	ModifiedTime time.Time `yaml:"-" json:"modified_time"`
+	File         string    `yaml:"-" json:"file"`
+	Path         string    `yaml:"-" json:"path"`
+	Source       string    `yaml:"-" json:"source"`
+	Key          string    `yaml:"-" json:"key"`
+	ContentHash  string    `yaml:"-" json:"content_hash"`
+	SourceHash   string    `yaml:"-" json:"source_hash"`
+}
+
+// sourceContent is the content directory of a source processed into a revision.
+type sourceContent struct {
+	source      *contentSource
+	contentRoot string
+}
+
+func (s *WebServer) processRevision(revision *Revision, contents []sourceContent) error {
+	started := time.Now()
+
+	// Sources are processed in the configured order, and files in lexical order, so when
+	// keys of articles collide, the same article is always published.
+	for _, content := range contents {
+		source, contentRoot := content.source, content.contentRoot
+		fileNames := []string{}
+		if err := fs.EnumerateDirectory(
+			contentRoot,
+			func(fileName string) {
+				fileNames = append(fileNames, fileName)
+			},
+		); err != nil {
+			return fmt.Errorf("failed to process data batch of repository %q: %v", source.Name, err)
+		}
+		sort.Strings(fileNames)
+
+		for _, fileName := range fileNames {
 			if !strings.HasSuffix(fileName, MarkdownExtension) {
 				s.warn("file %s does not have the expected extension %q and will not been processed", fileName, MarkdownExtension)
-				return
+				continue
 			}
-			if err := s.scanDataFile(revision, fileName); err != nil {
-				s.warn("failed to process data file %s: %v", fileName, err)
+			if err := s.scanDataFile(revision, source, filepath.Join(contentRoot, fileName)); err != nil {
+				s.warn("failed to process data file %s of repository %q: %v", fileName, source.Name, err)
 			}
-		},
-	); err != nil {
-		return fmt.Errorf("failed to process data batch: %v", err)
+		}
 	}
 
 	// Axiom: There is at least one article.
+	if len(revision.Articles) == 0 {
+		return errors.New("no articles found")
+	}
 
-	for _, article := range revision.Articles {
-		err := s.executor.ConvertMarkdownToHTML(
-			filepath.Join(revision.ContainerPath, article.File),                                            // input file
-			filepath.Join(s.env.CompiledWorkDirectory(), compiledHTMLTemplate(article.Key, revision.Hash)), // output file
-		)
-		if err != nil {
-			s.warn("failed to convert %s to HTML: %v", article.File, err)
-		}
+	reused, conversionErrors := s.convertArticles(revision)
+	for _, err := range conversionErrors {
+		s.warn("failed to convert %v", err)
 	}
 
+	s.organizeRevision(revision)
+
+	s.trace(
+		MarkdownProcessorTag,
+		"revision %s built in %v: %d articles, %d reused from cache, %d failed conversions",
+		revision.Hash,
+		time.Since(started),
+		len(revision.Articles),
+		reused,
+		len(conversionErrors),
+	)
+
+	return nil
+}
+
+// organizeRevision sorts out tags and groups of articles of the revision, and renders its
+// syndication feeds. It must be called after articles of the revision are converted to HTML.
+func (s *WebServer) organizeRevision(revision *Revision) {
 	// Sort out tags and associated articles.
 	for tag, articles := range revision.Tags {
 		sort.Slice(articles, func(i, j int) bool {
@@ -75,30 +127,132 @@ func (s *WebServer) processRevision(revision *Revision) error {
 	sort.Strings(revision.SortedTags)
 	revision.DefaultTag = revision.SortedTags[0]
 
+	// Determine when the most recently modified article was modified.
+	for _, article := range revision.Articles {
+		revision.LastModified = latest(revision.LastModified, lastModified(article))
+	}
+
 	// Sort out articles into groups.
 	revision.GroupsByDate = groupByDate(revision.Articles)
 	revision.GroupsByTitle = groupByTitle(revision.Articles)
 	revision.GroupsByType = groupByType(revision.Articles)
 
-	return nil
+	// Render syndication feeds from converted articles; historical revisions are not syndicated.
+	if !revision.historical {
+		s.generateFeeds(revision)
+	}
 }
 
-func (s *WebServer) scanDataFile(revision *Revision, fileName string) error {
-	file, err := fs.OpenFile(filepath.Join(revision.ContainerPath, fileName))
+// convertArticles converts all articles of the revision to HTML, running at most
+// the configured number of conversions at a time. It returns the number of articles
+// whose compiled output was reused from cache, and errors of failed conversions.
+func (s *WebServer) convertArticles(revision *Revision) (int, []error) {
+	var (
+		articles  = make(chan *Article)
+		reused    = 0
+		errs      = []error{}
+		lock      = &sync.Mutex{}
+		waitGroup = &sync.WaitGroup{}
+	)
+
+	for i := 0; i < s.settings.ConversionConcurrency; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for article := range articles {
+				cached, err := s.convertArticle(revision, article)
+				lock.Lock()
+				if cached {
+					reused++
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s to HTML: %v", article.File, err))
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+	// Articles with the same contents share compiled output, which is produced only once.
+	queued := make(map[string]bool)
+	for _, article := range revision.Articles {
+		if !queued[article.ContentHash] {
+			queued[article.ContentHash] = true
+			articles <- article
+		}
+	}
+	close(articles)
+	waitGroup.Wait()
+
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	return reused, errs
+}
+
+// convertArticle converts the article to HTML, unless the compiled output for the same content
+// and converter version is already in the cache. It returns a value indicating whether the output
+// was found in the cache.
+func (s *WebServer) convertArticle(revision *Revision, article *Article) (bool, error) {
+	outputFilePath := s.env.CompiledTemplatePath(compiledHTMLTemplate(article.ContentHash))
+	if exists, _ := fs.FileExists(outputFilePath); exists {
+		return true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.settings.ConversionTimeoutDur)
+	defer cancel()
+
+	// Write to a temporary file first, so that a failed conversion never leaves
+	// incomplete output in the cache. The same content can be converted by a sync and
+	// a historical build at the same time, so every conversion has its own temporary file.
+	inputFilePath := article.Path
+	partialFilePath := fmt.Sprintf("%s.%d%s", outputFilePath, conversionSequence.Add(1), PartialFileSuffix)
+	if err := s.converter.ConvertMarkdownToHTML(ctx, inputFilePath, partialFilePath); err != nil {
+		os.Remove(partialFilePath)
+		return false, err
+	}
+
+	return false, os.Rename(partialFilePath, outputFilePath)
+}
+
+// contentHash returns a hash of the data file contents and the converter version,
+// which identifies compiled output of the data file in the cache.
+func (s *WebServer) contentHash(content []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(s.converter.Name()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(s.converter.Version()))
+	hash.Write([]byte{0})
+	hash.Write(content)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// sourceHash returns a hash of the data file contents alone, which identifies the
+// content of an article regardless of the converter it is compiled with.
+func sourceHash(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+func (s *WebServer) scanDataFile(revision *Revision, source *contentSource, path string) error {
+	content, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
 	article := &Article{
-		File: fileName,
+		File:        filepath.Base(path),
+		Path:        path,
+		Source:      source.Name,
+		ContentHash: s.contentHash(content),
+		SourceHash:  sourceHash(content),
 	}
 
-	if err := yfm.Parse(file, article); err != nil {
+	if err := yfm.Parse(bytes.NewReader(content), article); err != nil {
 		return fmt.Errorf("failed to parse metadata: %v", err)
 	}
 
-	if !s.settings.PublishPrivateArticles && slice.ContainsString(article.Tags, PrivateArticleTag) {
+	if !s.isPublishable(article) {
 		return nil
 	}
 
@@ -106,23 +260,30 @@ func (s *WebServer) scanDataFile(revision *Revision, fileName string) error {
 		return fmt.Errorf("invalid metadata: %v", err)
 	}
 
-	// Cache article by key.
-	revision.Articles[article.Key] = article
+	article.Key = source.KeyPrefix + article.Key
+	if existing, found := revision.Articles[article.Key]; found {
+		return fmt.Errorf("key collision: key %s is already used by %s of repository %q", article.Key, existing.File, existing.Source)
+	}
 
 	// Ensure every article is tagged; articles without tags are tagged as private
 	// because they are considered to be incomplete.
 	if len(article.Tags) == 0 {
 		article.Tags = []string{PrivateArticleTag}
 	}
+	if source.DefaultTag != "" && !slice.ContainsString(article.Tags, source.DefaultTag) {
+		article.Tags = append(article.Tags, source.DefaultTag)
+	}
 
-	// Cache tags.
-	for _, tag := range article.Tags {
-		revision.Tags[tag] = append(revision.Tags[tag], article)
+	body, err := yfm.Body(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("failed to read body: %v", err)
 	}
+	revision.addArticle(article, plainText(body))
 
 	s.trace(
 		MarkdownProcessorTag,
-		"%s => [%s]: %q, tags:%v, created:%s, modified:%s",
+		"%s:%s => [%s]: %q, tags:%v, created:%s, modified:%s",
+		article.Source,
 		article.File,
 		article.Key,
 		article.Title,
@@ -134,6 +295,16 @@ func (s *WebServer) scanDataFile(revision *Revision, fileName string) error {
 	return nil
 }
 
+// isPublishable reports whether the article can be published according to the settings.
+func (s *WebServer) isPublishable(article *Article) bool {
+	return s.settings.PublishPrivateArticles || isPublicArticle(article)
+}
+
+// isPublicArticle reports whether the article is not tagged as private.
+func isPublicArticle(article *Article) bool {
+	return !slice.ContainsString(article.Tags, PrivateArticleTag)
+}
+
 func (a *Article) Normalize() (err error) {
 	if a.Title == "" {
 		err = errors.New("empty title")
@@ -165,6 +336,29 @@ func (a *Article) Normalize() (err error) {
 	return nil
 }
 
+// lastModified returns the time the article was last modified, or created if it was never modified.
+func lastModified(article *Article) time.Time {
+	if article.ModifiedTime.IsZero() {
+		return article.CreatedTime
+	}
+	return article.ModifiedTime
+}
+
+// latestModified returns the time the most recently modified article was last modified.
+func latestModified(articles []*Article) (modified time.Time) {
+	for _, article := range articles {
+		modified = latest(modified, lastModified(article))
+	}
+	return
+}
+
+func latest(a time.Time, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
 type groupBy struct {
 	determineGroup func(*Article) string
 	sort           func(groups []ArticleGroup)
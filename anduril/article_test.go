@@ -0,0 +1,287 @@
+package anduril_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+	"github.com/cicovic-andrija/anduril/service"
+)
+
+// testConverter is a markdown converter which records the files it converted, and writes
+// its name and version to the output. Conversions of files in fail fail, and conversions of
+// files in block last until they time out.
+type testConverter struct {
+	name       string
+	version    string
+	delay      time.Duration
+	fail       map[string]bool
+	block      map[string]bool
+	lock       sync.Mutex
+	converted  []string
+	running    int
+	maxRunning int
+}
+
+func (c *testConverter) Name() string {
+	return c.name
+}
+
+func (c *testConverter) Version() string {
+	return c.version
+}
+
+func (c *testConverter) ConvertMarkdownToHTML(ctx context.Context, inputFilePath string, outputFilePath string) error {
+	name := filepath.Base(inputFilePath)
+	c.lock.Lock()
+	c.converted = append(c.converted, name)
+	c.running++
+	if c.running > c.maxRunning {
+		c.maxRunning = c.running
+	}
+	c.lock.Unlock()
+	defer func() {
+		c.lock.Lock()
+		c.running--
+		c.lock.Unlock()
+	}()
+
+	if c.block[name] {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	time.Sleep(c.delay)
+	if c.fail[name] {
+		return errors.New("conversion failed")
+	}
+	return os.WriteFile(outputFilePath, []byte(fmt.Sprintf("<p>%s %s</p>", c.name, c.version)), 0644)
+}
+
+// conversions returns the sorted names of converted files, and forgets them.
+func (c *testConverter) conversions() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	sort.Strings(c.converted)
+	converted := strings.Join(c.converted, ",")
+	c.converted = nil
+	return converted
+}
+
+// newCompileTestServer returns a server of a single directory source, whose articles are
+// converted by the converter.
+func newCompileTestServer(t *testing.T, wd string, converter anduril.MarkdownConverter) (server *anduril.WebServer, notes string) {
+	notes = t.TempDir()
+	server = newTestServer(t, wd, func(config *anduril.Config) {
+		config.Repositories = []anduril.Source{newDirectorySource("notes", notes)}
+	})
+	server.SetConverter(converter)
+	return server, notes
+}
+
+// compiledFiles returns the sorted names of compiled files in the working directory.
+func compiledFiles(t *testing.T, wd string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(service.NewEnvironment(wd).CompiledWorkDirectory())
+	if err != nil {
+		t.Fatalf("compiled files: %v", err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestConvertArticleReusesCompiledFiles(t *testing.T) {
+	wd := t.TempDir()
+	converter := &testConverter{name: "test", version: "1"}
+	server, notes := newCompileTestServer(t, wd, converter)
+	writeArticle(t, notes, "go.md", "Go", "programming")
+	writeArticle(t, notes, "python.md", "Python", "programming")
+
+	first := syncSources(t, server, "notes")
+	if converted := converter.conversions(); converted != "go.md,python.md" {
+		t.Fatalf("converted: expected: go.md,python.md found: %s", converted)
+	}
+	compiled := compiledFiles(t, wd)
+	expected := []string{first.GetArticle("go").ContentHash + ".html", first.GetArticle("python").ContentHash + ".html"}
+	sort.Strings(expected)
+	if strings.Join(compiled, ",") != strings.Join(expected, ",") {
+		t.Fatalf("compiled files: expected: %v found: %v", expected, compiled)
+	}
+
+	// Only the changed article is converted again, unchanged articles reuse compiled files.
+	writeArticle(t, notes, "python.md", "Python 3", "programming")
+	second := syncSources(t, server, "notes")
+	if converted := converter.conversions(); converted != "python.md" {
+		t.Fatalf("converted: expected: python.md found: %s", converted)
+	}
+	if second.GetArticle("go").ContentHash != first.GetArticle("go").ContentHash {
+		t.Fatalf("content hash of an unchanged article changed")
+	}
+	if second.GetArticle("python").ContentHash == first.GetArticle("python").ContentHash {
+		t.Fatalf("content hash of a changed article did not change")
+	}
+}
+
+func TestConvertArticleConverterChange(t *testing.T) {
+	wd := t.TempDir()
+	server, notes := newCompileTestServer(t, wd, &testConverter{name: "test", version: "1"})
+	writeArticle(t, notes, "go.md", "Go", "programming")
+	hashes := map[string]bool{syncSources(t, server, "notes").GetArticle("go").ContentHash: true}
+
+	// Articles compiled by a different converter, or a different version of it, are converted again.
+	for i, converter := range []*testConverter{
+		{name: "test", version: "2"},
+		{name: "other", version: "2"},
+	} {
+		server.SetConverter(converter)
+		writeArticle(t, notes, fmt.Sprintf("article%d.md", i), "Article", "misc")
+		revision := syncSources(t, server, "notes")
+		if converted := converter.conversions(); !strings.Contains(converted, "go.md") {
+			t.Fatalf("%s %s: converted: expected go.md to be converted, found: %s", converter.name, converter.version, converted)
+		}
+
+		hash := revision.GetArticle("go").ContentHash
+		if hashes[hash] {
+			t.Fatalf("%s %s: content hash %s was used by another converter", converter.name, converter.version, hash)
+		}
+		hashes[hash] = true
+		content, err := os.ReadFile(filepath.Join(service.NewEnvironment(wd).CompiledWorkDirectory(), hash+".html"))
+		if err != nil {
+			t.Fatalf("%s %s: %v", converter.name, converter.version, err)
+		}
+		if expected := fmt.Sprintf("<p>%s %s</p>", converter.name, converter.version); string(content) != expected {
+			t.Fatalf("%s %s: compiled file: expected: %s found: %s", converter.name, converter.version, expected, content)
+		}
+	}
+}
+
+// newConversionRevision returns a revision of articles read from files in the directory,
+// with content hashes keyed by file name.
+func newConversionRevision(t *testing.T, dir string, hashes map[string]string) *anduril.Revision {
+	t.Helper()
+
+	revision := anduril.NewRevision()
+	for file, hash := range hashes {
+		path := filepath.Join(dir, file)
+		if err := os.WriteFile(path, []byte("# "+file), 0644); err != nil {
+			t.Fatal(err)
+		}
+		key := strings.TrimSuffix(file, anduril.MarkdownExtension)
+		revision.Articles[key] = &anduril.Article{Key: key, File: file, Path: path, ContentHash: hash}
+	}
+	return revision
+}
+
+func newConversionTestServer(t *testing.T, concurrency int, timeout string, converter anduril.MarkdownConverter) *anduril.WebServer {
+	server := newTestServer(t, t.TempDir(), func(config *anduril.Config) {
+		config.Settings.ConversionConcurrency = concurrency
+		config.Settings.ConversionTimeout = timeout
+	})
+	server.SetConverter(converter)
+	return server
+}
+
+func TestConvertArticlesConcurrency(t *testing.T) {
+	converter := &testConverter{name: "test", version: "1", delay: 20 * time.Millisecond}
+	server := newConversionTestServer(t, 3, "1m", converter)
+	hashes := make(map[string]string)
+	for i := 0; i < 12; i++ {
+		hashes[fmt.Sprintf("article%02d.md", i)] = fmt.Sprintf("hash%02d", i)
+	}
+
+	reused, errs := server.ConvertArticles(newConversionRevision(t, t.TempDir(), hashes))
+	if reused != 0 || len(errs) != 0 {
+		t.Fatalf("expected no reused articles and no errors, found: %d %v", reused, errs)
+	}
+	if converted := strings.Split(converter.conversions(), ","); len(converted) != len(hashes) {
+		t.Fatalf("converted: expected: %d found: %d", len(hashes), len(converted))
+	}
+	if converter.maxRunning != 3 {
+		t.Fatalf("concurrent conversions: expected: 3 found: %d", converter.maxRunning)
+	}
+}
+
+func TestConvertArticlesSharedContent(t *testing.T) {
+	converter := &testConverter{name: "test", version: "1"}
+	server := newConversionTestServer(t, 4, "1m", converter)
+	revision := newConversionRevision(t, t.TempDir(), map[string]string{
+		"go.md":     "shared",
+		"golang.md": "shared",
+		"rust.md":   "other",
+	})
+
+	// Articles with the same content are converted once, and share the compiled file.
+	if _, errs := server.ConvertArticles(revision); len(errs) != 0 {
+		t.Fatalf("expected no errors, found: %v", errs)
+	}
+	converted := converter.conversions()
+	if converted != "go.md,rust.md" && converted != "golang.md,rust.md" {
+		t.Fatalf("converted: expected one of go.md and golang.md, and rust.md, found: %s", converted)
+	}
+
+	reused, errs := server.ConvertArticles(revision)
+	if reused != 2 || len(errs) != 0 {
+		t.Fatalf("expected 2 reused articles and no errors, found: %d %v", reused, errs)
+	}
+	if converted := converter.conversions(); converted != "" {
+		t.Fatalf("converted: expected none, found: %s", converted)
+	}
+}
+
+func TestConvertArticlesErrors(t *testing.T) {
+	converter := &testConverter{
+		name:    "test",
+		version: "1",
+		fail:    map[string]bool{"c.md": true, "e.md": true},
+		block:   map[string]bool{"a.md": true},
+	}
+	server := newConversionTestServer(t, 2, "50ms", converter)
+	revision := newConversionRevision(t, t.TempDir(), map[string]string{
+		"a.md": "a", "b.md": "b", "c.md": "c", "d.md": "d", "e.md": "e",
+	})
+
+	// Errors of all conversions are collected, and conversions which take too long time out.
+	started := time.Now()
+	reused, errs := server.ConvertArticles(revision)
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Fatalf("conversions took %v", elapsed)
+	}
+	if reused != 0 {
+		t.Fatalf("reused: expected: 0 found: %d", reused)
+	}
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	expected := []string{
+		"a.md to HTML: " + context.DeadlineExceeded.Error(),
+		"c.md to HTML: conversion failed",
+		"e.md to HTML: conversion failed",
+	}
+	if strings.Join(messages, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("errors: expected: %v found: %v", expected, messages)
+	}
+
+	// Successful conversions are reused, and failed conversions are attempted again.
+	delete(converter.block, "a.md")
+	converter.conversions()
+	reused, errs = server.ConvertArticles(revision)
+	if reused != 2 || len(errs) != 2 {
+		t.Fatalf("expected 2 reused articles and 2 errors, found: %d %v", reused, errs)
+	}
+	if converted := converter.conversions(); converted != "a.md,c.md,e.md" {
+		t.Fatalf("converted: expected: a.md,c.md,e.md found: %s", converted)
+	}
+}
@@ -0,0 +1,184 @@
+package anduril
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cicovic-andrija/anduril/repository"
+)
+
+// Change history of articles, built from the history of the repository of the article,
+// and word-level diffs between any two revisions of an article.
+
+const (
+	// Suffix of the URL path of the history of an article.
+	ArticleHistorySuffix = "/history"
+	// Maximum number of revisions listed in the history of an article.
+	ArticleHistoryLimit = 100
+)
+
+// ArticleHistory is a list of revisions which changed an article, newest first,
+// and optionally a diff between two revisions of the article.
+type ArticleHistory struct {
+	Revisions []repository.FileRevision
+	From      string
+	To        string
+	Diff      []DiffSegment
+}
+
+func (s *WebServer) ArticleHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Path
+
+	// Reading a repository can take a while, and the revision is not read-locked in the
+	// meantime, so the article is looked up beforehand.
+	s.revisionLock.RLock()
+	revision := s.latestRevision
+	var article *Article
+	if revision != nil {
+		article = revision.GetArticle(key)
+	}
+	s.revisionLock.RUnlock()
+
+	if article == nil {
+		s.PageNotFoundHandler(w, r)
+		return
+	}
+	source := s.articleHistorySource(article)
+	if source == nil {
+		s.PageNotFoundHandler(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
+	if from == "" && to == "" {
+		err := s.serveCachedPage(
+			w,
+			r,
+			revision,
+			"articles/"+key+ArticleHistorySuffix,
+			revision.BuiltAt,
+			func(w io.Writer) error {
+				articleHistory, err := s.articleHistory(revision, source, article)
+				if err != nil {
+					return err
+				}
+				return s.renderPage(w, historyPage(article, revision, articleHistory))
+			},
+		)
+		if err != nil {
+			s.warn("failed to render history of article %s: %v", key, err)
+		}
+		return
+	}
+
+	if !revisionIDPattern.MatchString(from) || !revisionIDPattern.MatchString(to) {
+		http.Error(w, "Parameters from and to must be commit hashes.", http.StatusBadRequest)
+		return
+	}
+
+	// Only revisions listed in the history of the article can be compared,
+	// which bounds the number of diffs cached for the revision.
+	articleHistory, err := s.articleHistory(revision, source, article)
+	if err != nil {
+		s.warn("failed to read history of article %s: %v", key, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	from, to = articleHistory.revisionID(from), articleHistory.revisionID(to)
+	if from == "" || to == "" {
+		s.revisionNotFound(w, r)
+		return
+	}
+
+	err = s.serveCachedPage(
+		w,
+		r,
+		revision,
+		fmt.Sprintf("articles/%s%s?from=%s&to=%s", key, ArticleHistorySuffix, from, to),
+		revision.BuiltAt,
+		func(w io.Writer) error {
+			if err := s.articleDiff(source, article, articleHistory, from, to); err != nil {
+				return err
+			}
+			return s.renderPage(w, historyPage(article, revision, articleHistory))
+		},
+	)
+	if err != nil {
+		s.warn("failed to render diff of article %s between %s and %s: %v", key, from, to, err)
+	}
+}
+
+// articleHistorySource returns the source of the article,
+// or nil if the repository of the source does not keep history.
+func (s *WebServer) articleHistorySource(article *Article) *contentSource {
+	source := s.source(article.Source)
+	if source == nil {
+		return nil
+	}
+	if _, ok := source.repository.(repository.History); !ok {
+		return nil
+	}
+	return source
+}
+
+// articleHistory returns the history of the article in the revision,
+// which is read from the repository of the source once and cached in the revision.
+func (s *WebServer) articleHistory(revision *Revision, source *contentSource, article *Article) (*ArticleHistory, error) {
+	revision.historiesLock.Lock()
+	revisions, found := revision.histories[article.Key]
+	revision.historiesLock.Unlock()
+
+	if !found {
+		source.lock.RLock()
+		var err error
+		revisions, err = source.repository.(repository.History).FileHistory(article.File, ArticleHistoryLimit)
+		source.lock.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+
+		revision.historiesLock.Lock()
+		revision.histories[article.Key] = revisions
+		revision.historiesLock.Unlock()
+	}
+	return &ArticleHistory{Revisions: revisions}, nil
+}
+
+// revisionID returns the full ID of the listed revision identified by id, which can be
+// a prefix of the ID, or an empty string if id does not identify a single listed revision.
+func (h *ArticleHistory) revisionID(id string) string {
+	match := ""
+	for _, revision := range h.Revisions {
+		if strings.HasPrefix(revision.ID, id) {
+			if match != "" && match != revision.ID {
+				return ""
+			}
+			match = revision.ID
+		}
+	}
+	return match
+}
+
+// articleDiff adds a diff of the article between the revisions from and to to the history of the article.
+// The article is considered empty in a revision in which it does not exist.
+func (s *WebServer) articleDiff(source *contentSource, article *Article, articleHistory *ArticleHistory, from string, to string) error {
+	source.lock.RLock()
+	history := source.repository.(repository.History)
+	fromContent, fromErr := history.FileContent(from, article.File)
+	toContent, toErr := history.FileContent(to, article.File)
+	source.lock.RUnlock()
+
+	for _, err := range []error{fromErr, toErr} {
+		if err != nil && err != repository.ErrFileNotFound {
+			return err
+		}
+	}
+
+	articleHistory.From = from[:10]
+	articleHistory.To = to[:10]
+	articleHistory.Diff = WordDiff(string(fromContent), string(toContent))
+	return nil
+}
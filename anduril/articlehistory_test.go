@@ -0,0 +1,62 @@
+package anduril_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+)
+
+func TestArticleHistoryHandler(t *testing.T) {
+	source := newTestGitSource(t)
+	server := newTestServer(t, t.TempDir(), func(config *anduril.Config) {
+		config.Repositories = []anduril.Source{source.config("notes")}
+	})
+	get := func(query string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/articles/go/history"+query, nil)
+		request.URL.Path = "go"
+		recorder := httptest.NewRecorder()
+		server.ArticleHistoryHandler(recorder, request)
+		return recorder
+	}
+
+	unrelated := source.commit("python.md", "Python", "programming")
+	first := source.commit("go.md", "Go", "programming")
+	second := source.commit("go.md", "Go language", "programming")
+	syncSources(t, server, "notes")
+
+	response := get("")
+	if response.Code != http.StatusOK {
+		t.Fatalf("history: expected: %d found: %d", http.StatusOK, response.Code)
+	}
+	for _, commit := range []string{first, second} {
+		if !strings.Contains(response.Body.String(), commit[:10]) {
+			t.Fatalf("history: commit %s not listed", commit[:10])
+		}
+	}
+	if strings.Contains(response.Body.String(), unrelated[:10]) {
+		t.Fatalf("history: unrelated commit %s listed", unrelated[:10])
+	}
+
+	// Listed commits are compared, also by a prefix of their hash.
+	response = get("?from=" + first[:7] + "&to=" + second)
+	if response.Code != http.StatusOK {
+		t.Fatalf("diff: expected: %d found: %d", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "<ins>") || !strings.Contains(response.Body.String(), "Changes between") {
+		t.Fatalf("diff: diff not found in the page")
+	}
+
+	for query, code := range map[string]int{
+		"?from=" + unrelated[:10] + "&to=" + second[:10]: http.StatusNotFound,
+		"?from=0000000&to=" + second[:10]:                http.StatusNotFound,
+		"?from=" + first[:10]:                            http.StatusBadRequest,
+		"?from=HEAD&to=" + second[:10]:                   http.StatusBadRequest,
+	} {
+		if response := get(query); response.Code != code {
+			t.Fatalf("%s: expected: %d found: %d", query, code, response.Code)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package anduril
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cicovic-andrija/libgo/https"
+)
+
+// Static assets (stylesheets, scripts, icons) are served by the web server itself instead of
+// the file server built into the HTTPS server, so that text assets can be served precompressed.
+
+// AssetServer serves allowed files from the assets directory. Text assets are compressed
+// once, with the best compression level, when the server starts.
+type AssetServer struct {
+	config        https.FileServerConfig
+	allowed       map[string]bool
+	precompressed map[string]*precompressedAsset
+	fileServer    http.Handler
+}
+
+type precompressedAsset struct {
+	modTime     time.Time
+	size        int64
+	contentType string
+	encoded     map[string][]byte
+}
+
+func NewAssetServer(config https.FileServerConfig) *AssetServer {
+	assets := &AssetServer{
+		config:        config,
+		allowed:       make(map[string]bool),
+		precompressed: make(map[string]*precompressedAsset),
+		fileServer:    http.FileServer(http.Dir(config.Directory)),
+	}
+	for _, name := range config.Allowed {
+		assets.allowed[name] = true
+	}
+	return assets
+}
+
+// Precompress compresses all allowed text assets with all supported encodings,
+// and returns the names of assets which were precompressed.
+func (a *AssetServer) Precompress() ([]string, error) {
+	names := []string{}
+	for _, name := range a.config.Allowed {
+		contentType := mime.TypeByExtension(filepath.Ext(name))
+		if !isCompressible(contentType) {
+			continue
+		}
+
+		path := filepath.Join(a.config.Directory, filepath.FromSlash(name))
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		asset := &precompressedAsset{
+			modTime:     info.ModTime(),
+			size:        info.Size(),
+			contentType: contentType,
+			encoded:     make(map[string][]byte),
+		}
+		for _, encoding := range supportedEncodings {
+			if asset.encoded[encoding], err = encode(encoding, content, true /* best */); err != nil {
+				return nil, err
+			}
+		}
+		a.precompressed[name] = asset
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// ServeHTTP serves the asset identified by URL path, stripped of the assets URL prefix.
+func (a *AssetServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path
+	if !a.allowed[name] {
+		http.NotFound(w, r)
+		return
+	}
+
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+
+	// Range requests refer to the unencoded representation, which is served by the file server.
+	if r.Header.Get("Range") != "" {
+		encoding = ""
+	}
+
+	if asset, found := a.precompressed[name]; found && encoding != "" && asset.isCurrent(a.config.Directory, name) {
+		w.Header().Set("Content-Type", asset.contentType)
+		w.Header().Set("Content-Encoding", encoding)
+		http.ServeContent(w, r, name, asset.modTime, bytes.NewReader(asset.encoded[encoding]))
+		return
+	}
+
+	// Assets which are not precompressed, or were changed after the server started.
+	a.fileServer.ServeHTTP(w, r)
+}
+
+// registerAssetHandlers registers handlers which serve the assets, if serving assets is enabled.
+func (s *WebServer) registerAssetHandlers() {
+	if s.assets == nil {
+		return
+	}
+
+	prefix := s.assets.config.URLPrefix
+	if !strings.HasSuffix(prefix, https.URLSeparator) {
+		s.handle(prefix, https.Adapt(s.assets.fileServer, https.StripPrefix(prefix)))
+		return
+	}
+
+	s.handle(
+		prefix,
+		https.Adapt(
+			s.assets,
+			https.StripPrefix(prefix),
+			https.RedirectRootToParentTree,
+		),
+	)
+	s.handle(
+		strings.TrimSuffix(prefix, https.URLSeparator),
+		http.HandlerFunc(http.NotFound),
+	)
+}
+
+// isCurrent reports whether the asset file is unchanged since it was precompressed.
+func (p *precompressedAsset) isCurrent(directory string, name string) bool {
+	info, err := os.Stat(filepath.Join(directory, filepath.FromSlash(name)))
+	return err == nil && info.ModTime().Equal(p.modTime) && info.Size() == p.size
+}
@@ -0,0 +1,130 @@
+package anduril_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+	"github.com/cicovic-andrija/libgo/https"
+)
+
+const testStylesheet = "body { margin: 0 auto; max-width: 42rem; font-family: sans-serif; }\n"
+
+func newTestAssetServer(t *testing.T) (assets *anduril.AssetServer, directory string) {
+	t.Helper()
+
+	directory = t.TempDir()
+	for name, content := range map[string]string{
+		"style.css": testStylesheet,
+		"icon.png":  "\x89PNG\r\n\x1a\n",
+		"secret.js": "const secret = 42;\n",
+	} {
+		if err := os.WriteFile(filepath.Join(directory, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assets = anduril.NewAssetServer(https.FileServerConfig{
+		URLPrefix: "/assets/",
+		Directory: directory,
+		Allowed:   []string{"style.css", "icon.png"},
+	})
+	names, err := assets.Precompress()
+	if err != nil {
+		t.Fatalf("precompress: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"style.css"}) {
+		t.Fatalf("precompressed: expected: [style.css] found: %v", names)
+	}
+	return assets, directory
+}
+
+// getAsset requests the asset from the asset server wrapped the same way as by the web server.
+func getAsset(server *anduril.WebServer, assets *anduril.AssetServer, name string, header map[string]string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/assets/"+name, nil)
+	request.URL.Path = name
+	for key, value := range header {
+		request.Header.Set(key, value)
+	}
+	recorder := httptest.NewRecorder()
+	server.CompressResponse(assets).ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestAssetServerPrecompressed(t *testing.T) {
+	server := newTestServer(t, t.TempDir(), nil)
+	assets, _ := newTestAssetServer(t)
+
+	for _, encoding := range []string{"", anduril.GzipEncoding, anduril.BrotliEncoding} {
+		response := getAsset(server, assets, "style.css", map[string]string{"Accept-Encoding": encoding})
+		if response.Code != http.StatusOK {
+			t.Fatalf("%q: expected: %d found: %d", encoding, http.StatusOK, response.Code)
+		}
+		if found := response.Header().Get("Content-Encoding"); found != encoding {
+			t.Fatalf("%q: Content-Encoding: expected: %q found: %q", encoding, encoding, found)
+		}
+		if found := response.Header().Get("Content-Type"); !strings.HasPrefix(found, "text/css") {
+			t.Fatalf("%q: Content-Type: expected: text/css found: %s", encoding, found)
+		}
+		if found := response.Header().Get("Vary"); found != "Accept-Encoding" {
+			t.Fatalf("%q: Vary: expected: Accept-Encoding found: %q", encoding, found)
+		}
+		if body := decode(t, encoding, response.Body.Bytes()); string(body) != testStylesheet {
+			t.Fatalf("%q: decoded body: expected: %q found: %q", encoding, testStylesheet, body)
+		}
+	}
+
+	for _, name := range []string{"secret.js", "missing.css"} {
+		if response := getAsset(server, assets, name, nil); response.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected: %d found: %d", name, http.StatusNotFound, response.Code)
+		}
+	}
+}
+
+func TestAssetServerRange(t *testing.T) {
+	server := newTestServer(t, t.TempDir(), nil)
+	assets, _ := newTestAssetServer(t)
+
+	// Ranges are served from the unencoded asset, even if the client accepts an encoding.
+	response := getAsset(server, assets, "style.css", map[string]string{
+		"Accept-Encoding": "gzip, br",
+		"Range":           "bytes=0-3",
+	})
+	if response.Code != http.StatusPartialContent {
+		t.Fatalf("expected: %d found: %d", http.StatusPartialContent, response.Code)
+	}
+	if found := response.Header().Get("Content-Encoding"); found != "" {
+		t.Fatalf("Content-Encoding: expected none, found: %s", found)
+	}
+	if found := response.Body.String(); found != testStylesheet[:4] {
+		t.Fatalf("body: expected: %q found: %q", testStylesheet[:4], found)
+	}
+}
+
+func TestAssetServerChangedAsset(t *testing.T) {
+	server := newTestServer(t, t.TempDir(), nil)
+	assets, directory := newTestAssetServer(t)
+
+	// Assets changed after they were precompressed are served as they are on disk.
+	changed := "body { margin: 0; }\n"
+	path := filepath.Join(directory, "style.css")
+	if err := os.WriteFile(path, []byte(changed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	response := getAsset(server, assets, "style.css", map[string]string{"Accept-Encoding": anduril.GzipEncoding})
+	encoding := response.Header().Get("Content-Encoding")
+	if body := decode(t, encoding, response.Body.Bytes()); string(body) != changed {
+		t.Fatalf("decoded body: expected: %q found: %q", changed, body)
+	}
+}
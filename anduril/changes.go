@@ -0,0 +1,214 @@
+package anduril
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Recent changes of articles. Every new revision is compared with the previous one, and
+// articles added, modified, renamed and removed by it are recorded in the revision, along
+// with the most recent changes recorded by previous revisions.
+
+// Maximum number of changes recorded in a revision.
+const RecentChangeLimit = 100
+
+// URL path of the list of recent changes, and of its feed.
+const (
+	ChangesPath     = "/changes"
+	ChangesFeedPath = "changes/" + AtomFeedFile
+)
+
+// ChangeKind is a kind of change of an article between two revisions.
+type ChangeKind string
+
+// Change kinds enum.
+const (
+	ArticleAdded    ChangeKind = "added"
+	ArticleModified ChangeKind = "modified"
+	ArticleRenamed  ChangeKind = "renamed"
+	ArticleRemoved  ChangeKind = "removed"
+)
+
+// ArticleChange is a change of an article introduced by a revision.
+type ArticleChange struct {
+	Kind  ChangeKind `json:"kind"`
+	Key   string     `json:"key"`
+	Title string     `json:"title"`
+	// Key of the article before it was renamed.
+	PreviousKey string `json:"previous_key,omitempty"`
+	// Hash of the revision which introduced the change.
+	Revision string `json:"revision"`
+	// Commit of the article's repository which introduced the change, or in which
+	// the article last existed if it was removed.
+	Commit string    `json:"commit"`
+	Time   time.Time `json:"time"`
+	Public bool      `json:"public"`
+}
+
+// CompareRevisions returns changes of articles between the previous and the current revision,
+// ordered by key. Articles removed and added with the same content in the same repository
+// are reported as renamed. Only articles of repositories merged into both revisions are
+// compared, so that a repository which is added, removed, or not synced yet after a restart
+// does not appear to add or remove all of its articles.
+func CompareRevisions(previous *Revision, current *Revision) []ArticleChange {
+	var (
+		changes = []ArticleChange{}
+		removed = make(map[string]*Article)
+	)
+
+	compared := func(article *Article) bool {
+		return sourceCommit(previous, article.Source) != "" && sourceCommit(current, article.Source) != ""
+	}
+
+	newChange := func(kind ChangeKind, article *Article, revision *Revision) ArticleChange {
+		return ArticleChange{
+			Kind:     kind,
+			Key:      article.Key,
+			Title:    article.Title,
+			Revision: current.Hash,
+			Commit:   sourceCommit(revision, article.Source),
+			Time:     current.BuiltAt,
+			Public:   isPublicArticle(article),
+		}
+	}
+
+	// Renamed articles are matched by their content within the same repository.
+	contentID := func(article *Article) string {
+		if article.SourceHash == "" {
+			return article.Source + "\x00" + article.ContentHash
+		}
+		return article.Source + "\x00" + article.SourceHash
+	}
+
+	for key, article := range previous.Articles {
+		if _, found := current.Articles[key]; !found && compared(article) {
+			removed[contentID(article)] = article
+		}
+	}
+
+	for key, article := range current.Articles {
+		if !compared(article) {
+			continue
+		}
+		if old, found := previous.Articles[key]; found {
+			if !sameSource(old, article) {
+				changes = append(changes, newChange(ArticleModified, article, current))
+			}
+			continue
+		}
+
+		if old, found := removed[contentID(article)]; found {
+			delete(removed, contentID(article))
+			change := newChange(ArticleRenamed, article, current)
+			change.PreviousKey = old.Key
+			changes = append(changes, change)
+			continue
+		}
+
+		changes = append(changes, newChange(ArticleAdded, article, current))
+	}
+
+	for _, article := range removed {
+		changes = append(changes, newChange(ArticleRemoved, article, previous))
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// sameSource reports whether the data files of the articles have the same content. Compiled
+// output is not compared, so that upgrading or switching the converter does not modify articles.
+// Articles restored from state saved before source hashes were recorded are compared by the
+// hash of their compiled output instead.
+func sameSource(a *Article, b *Article) bool {
+	if a.SourceHash == "" || b.SourceHash == "" {
+		return a.ContentHash == b.ContentHash
+	}
+	return a.SourceHash == b.SourceHash
+}
+
+// sourceCommit returns the short hash of the revision of the named source merged into the revision.
+func sourceCommit(revision *Revision, name string) string {
+	for _, source := range revision.Sources {
+		if source.Name == name {
+			if len(source.Hash) > 10 {
+				return source.Hash[:10]
+			}
+			return source.Hash
+		}
+	}
+	return ""
+}
+
+// recordChanges records changes of articles between the previous revision (nil if there is none)
+// and the new revision in the new revision, and renders the feed of recent changes.
+func (s *WebServer) recordChanges(previous *Revision, revision *Revision) {
+	if previous != nil {
+		revision.Changes = append(CompareRevisions(previous, revision), previous.Changes...)
+		if len(revision.Changes) > RecentChangeLimit {
+			revision.Changes = revision.Changes[:RecentChangeLimit]
+		}
+	}
+
+	s.generateChangesFeed(revision)
+}
+
+// generateChangesFeed renders the feed of changes recorded in the revision.
+func (s *WebServer) generateChangesFeed(revision *Revision) {
+	feed, err := s.renderChangesFeed(revision.Changes)
+	if err != nil {
+		s.warn("failed to render feed %s: %v", ChangesFeedPath, err)
+		return
+	}
+	revision.Feeds[ChangesFeedPath] = feed
+}
+
+// renderChangesFeed renders an Atom feed of changes of public articles.
+func (s *WebServer) renderChangesFeed(changes []ArticleChange) ([]byte, error) {
+	changesURL := s.absoluteURL(ChangesPath)
+	feed := atomFeed{
+		Title: StaticPages["home"].Title + ": Recent changes",
+		ID:    s.absoluteURL("/" + ChangesFeedPath),
+		Author: atomPerson{
+			Name: s.settings.SiteAuthor,
+		},
+		Links: []atomLink{
+			{Href: s.absoluteURL("/" + ChangesFeedPath), Rel: "self", Type: "application/atom+xml"},
+			{Href: changesURL, Rel: "alternate", Type: "text/html"},
+		},
+	}
+
+	var updated time.Time
+	for _, change := range changes {
+		if !change.Public {
+			continue
+		}
+		if len(feed.Entries) == FeedEntryLimit {
+			break
+		}
+		updated = latest(updated, change.Time)
+
+		link := s.absoluteURL("/articles/" + change.Key)
+		if change.Kind == ArticleRemoved {
+			link = changesURL
+		}
+		summary := fmt.Sprintf("Article %s %s.", change.Key, change.Kind)
+		if change.Kind == ArticleRenamed {
+			summary = fmt.Sprintf("Article %s renamed to %s.", change.PreviousKey, change.Key)
+		}
+
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   fmt.Sprintf("%s (%s)", change.Title, change.Kind),
+			ID:      fmt.Sprintf("%s#%s-%s", changesURL, change.Revision, change.Key),
+			Updated: change.Time.Format(time.RFC3339),
+			Links:   []atomLink{{Href: link, Rel: "alternate", Type: "text/html"}},
+			Summary: &atomText{Type: "text", Body: summary},
+		})
+	}
+	feed.Updated = updated.Format(time.RFC3339)
+
+	return marshalFeed(feed)
+}
@@ -0,0 +1,51 @@
+package anduril_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+)
+
+func TestCompareRevisions(t *testing.T) {
+	previous := anduril.NewRevision()
+	previous.Sources = []anduril.SourceRevision{{Name: "notes", Hash: "a"}}
+	current := anduril.NewRevision()
+	current.Sources = []anduril.SourceRevision{{Name: "notes", Hash: "b"}, {Name: "runbooks", Hash: "c"}}
+	for _, article := range []*anduril.Article{
+		{Key: "kept", SourceHash: "1", ContentHash: "pandoc-1", Source: "notes"},
+		{Key: "edited", SourceHash: "2", Source: "notes"},
+		{Key: "old-name", SourceHash: "3", Source: "notes"},
+		{Key: "deleted", SourceHash: "4", Source: "notes"},
+	} {
+		previous.Articles[article.Key] = article
+	}
+	for _, article := range []*anduril.Article{
+		// Articles compiled with another converter are not modified.
+		{Key: "kept", SourceHash: "1", ContentHash: "builtin-1", Source: "notes"},
+		{Key: "edited", SourceHash: "5", Source: "notes"},
+		{Key: "new-name", SourceHash: "3", Source: "notes"},
+		{Key: "created", SourceHash: "6", Source: "notes"},
+		// Articles of repositories which are not in both revisions are not compared.
+		{Key: "runbook", SourceHash: "7", Source: "runbooks"},
+	} {
+		current.Articles[article.Key] = article
+	}
+
+	found := map[string]anduril.ChangeKind{}
+	for _, change := range anduril.CompareRevisions(previous, current) {
+		found[change.Key] = change.Kind
+		if change.Kind == anduril.ArticleRenamed && change.PreviousKey != "old-name" {
+			t.Fatalf("renamed: expected previous key: old-name found: %s", change.PreviousKey)
+		}
+	}
+	expected := map[string]anduril.ChangeKind{
+		"edited":   anduril.ArticleModified,
+		"new-name": anduril.ArticleRenamed,
+		"deleted":  anduril.ArticleRemoved,
+		"created":  anduril.ArticleAdded,
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Fatalf("changes: expected: %v found: %v", expected, found)
+	}
+}
@@ -0,0 +1,192 @@
+package anduril
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Supported content encodings, in order of preference.
+const (
+	BrotliEncoding = "br"
+	GzipEncoding   = "gzip"
+)
+
+var supportedEncodings = []string{BrotliEncoding, GzipEncoding}
+
+// CompressResponse is an https.Adapter used to compress text responses with the content
+// encoding negotiated from the Accept-Encoding request header. Responses which already
+// have a content encoding set by the underlying handler are passed through unchanged.
+func (s *WebServer) CompressResponse(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+
+		// Range requests refer to the unencoded representation.
+		if r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+			encoding = ""
+		}
+
+		cw := &compressingResponseWriter{
+			ResponseWriter: w,
+			encoding:       encoding,
+		}
+		defer func() {
+			if err := cw.Close(); err != nil {
+				s.warn("failed to compress response to %s: %v", r.URL.Path, err)
+			}
+		}()
+
+		// Call the next handler in the chain.
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// compressingResponseWriter decides whether to compress the response once the
+// response status and headers are known, i.e. on the first write.
+type compressingResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+	encoder  io.WriteCloser
+	decided  bool
+}
+
+func (cw *compressingResponseWriter) WriteHeader(status int) {
+	if !cw.decided {
+		cw.decide(status)
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressingResponseWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *compressingResponseWriter) Close() error {
+	if cw.encoder != nil {
+		return cw.encoder.Close()
+	}
+	return nil
+}
+
+func (cw *compressingResponseWriter) decide(status int) {
+	cw.decided = true
+	header := cw.Header()
+
+	alreadyEncoded := header.Get("Content-Encoding") != ""
+	if !alreadyEncoded && !isCompressible(header.Get("Content-Type")) {
+		return
+	}
+	header.Add("Vary", "Accept-Encoding")
+
+	if alreadyEncoded || cw.encoding == "" || status < http.StatusOK ||
+		status == http.StatusNoContent || status == http.StatusNotModified {
+		return
+	}
+
+	header.Set("Content-Encoding", cw.encoding)
+	header.Del("Content-Length")
+	cw.encoder = newEncoder(cw.encoding, cw.ResponseWriter, false /* best */)
+}
+
+// negotiateEncoding returns the most preferred supported encoding acceptable
+// according to the Accept-Encoding header value, or an empty string if the
+// response should not be encoded.
+func negotiateEncoding(acceptEncoding string) string {
+	var (
+		qualities = make(map[string]float64)
+		wildcard  = -1.0
+	)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		quality := 1.0
+		if name, value, found := strings.Cut(strings.TrimSpace(params), "="); found && strings.TrimSpace(name) == "q" {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				quality = q
+			}
+		}
+
+		if coding == "*" {
+			wildcard = quality
+		} else {
+			qualities[coding] = quality
+		}
+	}
+
+	best, bestQuality := "", 0.0
+	for _, encoding := range supportedEncodings {
+		quality, found := qualities[encoding]
+		if !found {
+			quality = wildcard
+		}
+		if quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
+
+// encode returns data compressed with the encoding.
+func encode(encoding string, data []byte, best bool) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := newEncoder(encoding, &buffer, best)
+	if _, err := encoder.Write(data); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// newEncoder returns a writer which compresses data written to it with the encoding.
+// Best compression is slow and should be used only for data which is compressed once.
+func newEncoder(encoding string, w io.Writer, best bool) io.WriteCloser {
+	switch encoding {
+	case BrotliEncoding:
+		if best {
+			return brotli.NewWriterLevel(w, brotli.BestCompression)
+		}
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	default:
+		level := gzip.DefaultCompression
+		if best {
+			level = gzip.BestCompression
+		}
+		gw, _ := gzip.NewWriterLevel(w, level) // level is always valid
+		return gw
+	}
+}
+
+func isCompressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case strings.HasSuffix(mediaType, "+xml"), strings.HasSuffix(mediaType, "+json"):
+		return true
+	case mediaType == "application/json", mediaType == "application/xml", mediaType == "application/javascript":
+		return true
+	default:
+		return false
+	}
+}
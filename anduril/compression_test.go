@@ -0,0 +1,161 @@
+package anduril_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/cicovic-andrija/anduril/anduril"
+)
+
+const testPage = "<!DOCTYPE html><html><body><p>Compressed page.</p></body></html>"
+
+// decode returns the body decoded with the content encoding.
+func decode(t *testing.T, encoding string, body []byte) []byte {
+	t.Helper()
+
+	var (
+		reader io.Reader = bytes.NewReader(body)
+		err    error
+	)
+	switch encoding {
+	case "":
+	case anduril.GzipEncoding:
+		if reader, err = gzip.NewReader(reader); err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+	case anduril.BrotliEncoding:
+		reader = brotli.NewReader(reader)
+	default:
+		t.Fatalf("unexpected encoding: %s", encoding)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("%s: %v", encoding, err)
+	}
+	return decoded
+}
+
+func serveCompressed(server *anduril.WebServer, h http.HandlerFunc, r *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	server.CompressResponse(h).ServeHTTP(recorder, r)
+	return recorder
+}
+
+func servePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, testPage)
+}
+
+func TestCompressResponseNegotiation(t *testing.T) {
+	server := newTestServer(t, t.TempDir(), nil)
+	for _, test := range []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"deflate", ""},
+		{"gzip", anduril.GzipEncoding},
+		{"br", anduril.BrotliEncoding},
+		{"GZIP", anduril.GzipEncoding},
+		{"gzip, deflate, br", anduril.BrotliEncoding},
+		{"br;q=0.5, gzip", anduril.GzipEncoding},
+		{"br;q=0.5, gzip;q=0.8", anduril.GzipEncoding},
+		{"br;q=0.8, gzip;q=0.8", anduril.BrotliEncoding},
+		{"br;q=0, gzip;q=0", ""},
+		{"*", anduril.BrotliEncoding},
+		{"*;q=0", ""},
+		{"br;q=0, *", anduril.GzipEncoding},
+		{"*;q=0.1, gzip;q=0.5", anduril.GzipEncoding},
+		{"gzip;q=invalid", anduril.GzipEncoding},
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/home", nil)
+		request.Header.Set("Accept-Encoding", test.acceptEncoding)
+		response := serveCompressed(server, servePage, request)
+
+		if found := response.Header().Get("Content-Encoding"); found != test.expected {
+			t.Fatalf("%q: Content-Encoding: expected: %q found: %q", test.acceptEncoding, test.expected, found)
+		}
+		if found := response.Header().Get("Vary"); found != "Accept-Encoding" {
+			t.Fatalf("%q: Vary: expected: Accept-Encoding found: %q", test.acceptEncoding, found)
+		}
+		if body := decode(t, test.expected, response.Body.Bytes()); string(body) != testPage {
+			t.Fatalf("%q: decoded body: expected: %q found: %q", test.acceptEncoding, testPage, body)
+		}
+	}
+}
+
+func TestCompressResponseSkipped(t *testing.T) {
+	server := newTestServer(t, t.TempDir(), nil)
+	image := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		io.WriteString(w, testPage)
+	}
+	notModified := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusNotModified)
+	}
+	detected := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, testPage)
+	}
+
+	for _, test := range []struct {
+		name    string
+		method  string
+		header  map[string]string
+		handler http.HandlerFunc
+		encoded bool
+		vary    bool
+	}{
+		{"get", http.MethodGet, nil, servePage, true, true},
+		{"detected content type", http.MethodGet, nil, detected, true, true},
+		{"head", http.MethodHead, nil, servePage, false, true},
+		{"range", http.MethodGet, map[string]string{"Range": "bytes=0-9"}, servePage, false, true},
+		{"not modified", http.MethodGet, nil, notModified, false, true},
+		{"not compressible", http.MethodGet, nil, image, false, false},
+	} {
+		request := httptest.NewRequest(test.method, "/home", nil)
+		request.Header.Set("Accept-Encoding", "gzip")
+		for name, value := range test.header {
+			request.Header.Set(name, value)
+		}
+		response := serveCompressed(server, test.handler, request)
+
+		if encoded := response.Header().Get("Content-Encoding") == anduril.GzipEncoding; encoded != test.encoded {
+			t.Fatalf("%s: encoded: expected: %v found: %v", test.name, test.encoded, encoded)
+		}
+		if vary := response.Header().Get("Vary") == "Accept-Encoding"; vary != test.vary {
+			t.Fatalf("%s: Vary: expected: %v found: %v", test.name, test.vary, vary)
+		}
+	}
+}
+
+func TestCompressResponsePassthrough(t *testing.T) {
+	server := newTestServer(t, t.TempDir(), nil)
+	encoded := []byte("already encoded by the handler")
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		w.Header().Set("Content-Encoding", anduril.BrotliEncoding)
+		w.Write(encoded)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/assets/style.css", nil)
+	request.Header.Set("Accept-Encoding", "gzip, br")
+	response := serveCompressed(server, handler, request)
+
+	if found := response.Header().Get("Content-Encoding"); found != anduril.BrotliEncoding {
+		t.Fatalf("Content-Encoding: expected: %s found: %s", anduril.BrotliEncoding, found)
+	}
+	if found := response.Header().Values("Vary"); strings.Join(found, ",") != "Accept-Encoding" {
+		t.Fatalf("Vary: expected: Accept-Encoding found: %v", found)
+	}
+	if !bytes.Equal(response.Body.Bytes(), encoded) {
+		t.Fatalf("body: expected: %q found: %q", encoded, response.Body.Bytes())
+	}
+}
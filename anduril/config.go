@@ -1,7 +1,13 @@
 package anduril
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cicovic-andrija/anduril/repository"
@@ -9,21 +15,113 @@ import (
 )
 
 type Config struct {
-	HTTPS      https.Config      `json:"https"`
-	Repository repository.Config `json:"repository"`
-	Settings   Settings          `json:"settings"`
+	HTTPS        https.Config `json:"https"`
+	Repositories []Source     `json:"repositories"`
+	Settings     Settings     `json:"settings"`
+
+	// Repository is the single repository of configurations written before multiple
+	// repositories were supported; see MigrateLegacyRepository.
+	Repository *repository.Config `json:"repository,omitempty"`
+}
+
+// Name of the source of the single repository of a legacy configuration.
+const LegacySourceName = "default"
+
+// MigrateLegacyRepository converts the single repository of a legacy configuration into
+// the only source, named LegacySourceName, and reports whether the configuration was
+// converted. Legacy and current repository configurations cannot be combined.
+func (c *Config) MigrateLegacyRepository() (bool, error) {
+	if c.Repository == nil {
+		return false, nil
+	}
+	if len(c.Repositories) > 0 {
+		return false, errors.New("repository: cannot be combined with repositories, move it into the repositories list")
+	}
+	c.Repositories = []Source{{Name: LegacySourceName, Repository: *c.Repository}}
+	c.Repository = nil
+	return true, nil
+}
+
+// DefaultSiteURL returns the URL of the site derived from the address the server listens on,
+// which is used when settings.site_url is not set. The public name of a server which accepts
+// connections from any host is not known, so it is addressed as localhost.
+func (c *Config) DefaultSiteURL() string {
+	host := c.HTTPS.Network.IPAcceptHost
+	if host == "" || host == "any" {
+		host = "localhost"
+	}
+	if c.HTTPS.Network.TCPPort == 443 {
+		return "https://" + host
+	}
+	return "https://" + net.JoinHostPort(host, strconv.Itoa(c.HTTPS.Network.TCPPort))
 }
 
+// Default values of optional settings.
+const (
+	DefaultSearchSuggestionLimit = 8
+	DefaultConversionTimeout     = "1m"
+	DefaultRetainedRevisions     = 5
+	DefaultHistoricalRevisions   = 8
+)
+
 type Settings struct {
+	SiteURL                   string        `json:"site_url"`
+	SiteAuthor                string        `json:"site_author"`
 	PublishPrivateArticles    bool          `json:"publish_private_articles"`
+	MarkdownConverter         string        `json:"markdown_converter"`
+	ConversionConcurrency     int           `json:"conversion_concurrency"`
+	ConversionTimeout         string        `json:"conversion_timeout"`
+	ConversionTimeoutDur      time.Duration `json:"-"`
 	RepositorySyncPeriod      string        `json:"repository_sync_period"`
 	RepositorySyncPeriodDur   time.Duration `json:"-"`
 	StaleFileCleanupPeriod    string        `json:"stale_file_cleanup_period"`
 	StaleFileCleanupPeriodDur time.Duration `json:"-"`
+	WebhookSecret             string        `json:"webhook_secret"`
+	AdminToken                string        `json:"admin_token"`
+	RetainedRevisions         int           `json:"retained_revisions"`
+	HistoricalRevisions       int           `json:"historical_revisions"`
+	SearchSuggestionLimit     int           `json:"search_suggestion_limit"`
+	RobotsDisallow            []string      `json:"robots_disallow"`
+	ReloadTemplates           bool          `json:"reload_templates"`
 }
 
 func (s *Settings) Validate() error {
-	dur, err := time.ParseDuration(s.RepositorySyncPeriod)
+	// Feeds and sitemaps need absolute URLs. When the site URL is not configured, the web server
+	// sets it to Config.DefaultSiteURL before the settings are validated.
+	siteURL, err := url.Parse(s.SiteURL)
+	if err != nil {
+		return fmt.Errorf("site URL: %v", err)
+	}
+	if !siteURL.IsAbs() || siteURL.Host == "" {
+		return errors.New("site URL: must be an absolute URL")
+	}
+	s.SiteURL = strings.TrimSuffix(s.SiteURL, "/")
+
+	switch s.MarkdownConverter {
+	case "":
+		s.MarkdownConverter = PandocConverter
+	case PandocConverter, BuiltinConverter:
+	default:
+		return fmt.Errorf("markdown converter: allowed values are %q and %q", PandocConverter, BuiltinConverter)
+	}
+
+	if s.ConversionConcurrency < 0 {
+		return fmt.Errorf("conversion concurrency: negative value: %d", s.ConversionConcurrency)
+	}
+	if s.ConversionConcurrency == 0 {
+		s.ConversionConcurrency = runtime.NumCPU()
+	}
+
+	if s.ConversionTimeout == "" {
+		s.ConversionTimeout = DefaultConversionTimeout
+	}
+	dur, err := time.ParseDuration(s.ConversionTimeout)
+	if err != nil {
+		return fmt.Errorf("conversion timeout: %v", err)
+	}
+	s.ConversionTimeoutDur = dur
+
+	dur, err = time.ParseDuration(s.RepositorySyncPeriod)
 	if err != nil {
 		return fmt.Errorf("repository sync period: %v", err)
 	}
@@ -35,5 +133,32 @@ func (s *Settings) Validate() error {
 	}
 	s.StaleFileCleanupPeriodDur = dur
 
+	if s.RetainedRevisions < 0 {
+		return fmt.Errorf("retained revisions: negative value: %d", s.RetainedRevisions)
+	}
+	if s.RetainedRevisions == 0 {
+		s.RetainedRevisions = DefaultRetainedRevisions
+	}
+
+	if s.HistoricalRevisions < 0 {
+		return fmt.Errorf("historical revisions: negative value: %d", s.HistoricalRevisions)
+	}
+	if s.HistoricalRevisions == 0 {
+		s.HistoricalRevisions = DefaultHistoricalRevisions
+	}
+
+	if s.SearchSuggestionLimit < 0 {
+		return fmt.Errorf("search suggestion limit: negative value: %d", s.SearchSuggestionLimit)
+	}
+	if s.SearchSuggestionLimit == 0 {
+		s.SearchSuggestionLimit = DefaultSearchSuggestionLimit
+	}
+
+	for _, path := range s.RobotsDisallow {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("robots disallow: path must start with '/': %q", path)
+		}
+	}
+
 	return nil
 }
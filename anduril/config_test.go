@@ -0,0 +1,71 @@
+package anduril_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+	"github.com/cicovic-andrija/libgo/https"
+)
+
+func TestDefaultSiteURL(t *testing.T) {
+	for _, test := range []struct {
+		host     string
+		port     int
+		expected string
+	}{
+		{"localhost", 8080, "https://localhost:8080"},
+		{"any", 8443, "https://localhost:8443"},
+		{"any", 443, "https://localhost"},
+	} {
+		config := &anduril.Config{HTTPS: https.Config{Network: https.NetworkConfig{IPAcceptHost: test.host, TCPPort: test.port}}}
+		if found := config.DefaultSiteURL(); found != test.expected {
+			t.Fatalf("%s:%d: expected: %s found: %s", test.host, test.port, test.expected, found)
+		}
+	}
+}
+
+func TestServerWithoutSiteURL(t *testing.T) {
+	// Configurations written before the site URL was added still start.
+	server := newTestServer(t, t.TempDir(), func(config *anduril.Config) {
+		config.Settings.SiteURL = ""
+	})
+
+	response := httptest.NewRecorder()
+	server.RobotsHandlerLocked(response, httptest.NewRequest(http.MethodGet, "https://localhost/robots.txt", nil))
+	if sitemap := "Sitemap: https://localhost:8443/sitemap.xml"; !strings.Contains(response.Body.String(), sitemap) {
+		t.Fatalf("robots.txt: expected %q in:\n%s", sitemap, response.Body.String())
+	}
+}
+
+func TestSiteURLValidation(t *testing.T) {
+	for _, test := range []struct {
+		siteURL  string
+		expected string
+		valid    bool
+	}{
+		{"https://www.example.com", "https://www.example.com", true},
+		{"https://www.example.com/", "https://www.example.com", true},
+		{"https://www.example.com/notes/", "https://www.example.com/notes", true},
+		{"www.example.com", "", false},
+		{"/notes", "", false},
+		{"https://", "", false},
+		// Left empty only if the web server did not fall back to the default site URL.
+		{"", "", false},
+	} {
+		settings := &anduril.Settings{
+			SiteURL:                test.siteURL,
+			RepositorySyncPeriod:   "1h",
+			StaleFileCleanupPeriod: "1h",
+		}
+		err := settings.Validate()
+		if valid := err == nil; valid != test.valid {
+			t.Fatalf("%q: valid: expected: %v found: %v (%v)", test.siteURL, test.valid, valid, err)
+		}
+		if test.valid && settings.SiteURL != test.expected {
+			t.Fatalf("%q: expected: %s found: %s", test.siteURL, test.expected, settings.SiteURL)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package anduril
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	"github.com/cicovic-andrija/anduril/service"
+	"github.com/cicovic-andrija/anduril/yfm"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// Names of supported markdown converters, as used in settings.
+const (
+	PandocConverter  = "pandoc"
+	BuiltinConverter = "builtin"
+)
+
+const goldmarkModulePath = "github.com/yuin/goldmark"
+
+// MarkdownConverter converts data files (articles) in markdown format to HTML.
+type MarkdownConverter interface {
+	// Name returns the name of the converter, as used in settings.
+	Name() string
+
+	// Version returns a value which changes whenever the converter may start producing different output.
+	Version() string
+
+	// ConvertMarkdownToHTML converts the markdown file found on inputFilePath to an HTML fragment,
+	// and writes the fragment to outputFilePath. The conversion fails when ctx is done, but it
+	// must not return before the work it started has stopped.
+	ConvertMarkdownToHTML(ctx context.Context, inputFilePath string, outputFilePath string) error
+}
+
+// NativeConverter is an in-process markdown converter which supports CommonMark with
+// GitHub Flavored Markdown tables, task lists, strikethrough and autolinks, and footnotes.
+// A conversion can't be interrupted, so the conversion timeout only discards its result.
+type NativeConverter struct {
+	markdown goldmark.Markdown
+	trace    service.TraceCallback
+}
+
+func NewNativeConverter(trace service.TraceCallback) *NativeConverter {
+	return &NativeConverter{
+		markdown: goldmark.New(
+			goldmark.WithExtensions(
+				extension.GFM,
+				extension.Footnote,
+			),
+			goldmark.WithParserOptions(
+				parser.WithAutoHeadingID(),
+			),
+			goldmark.WithRendererOptions(
+				// Raw HTML is passed through, the same way pandoc does it.
+				html.WithUnsafe(),
+			),
+		),
+		trace: trace,
+	}
+}
+
+func (c *NativeConverter) Name() string {
+	return BuiltinConverter
+}
+
+func (c *NativeConverter) Version() string {
+	// Output depends only on the version of the markdown library.
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == goldmarkModulePath {
+				return fmt.Sprintf("%s %s", goldmarkModulePath, dep.Version)
+			}
+		}
+	}
+	return fmt.Sprintf("%s (unknown)", goldmarkModulePath)
+}
+
+func (c *NativeConverter) ConvertMarkdownToHTML(ctx context.Context, inputFilePath string, outputFilePath string) error {
+	content, err := os.ReadFile(inputFilePath)
+	if err != nil {
+		return err
+	}
+
+	// Metadata is not a part of the rendered document.
+	body, err := yfm.Body(bytes.NewReader(content))
+	if err == yfm.ErrNotFound {
+		body = content
+	} else if err != nil {
+		return err
+	}
+
+	// Conversions by goldmark can't be cancelled. The conversion runs to completion in the
+	// calling goroutine, so that it keeps its conversion slot until then, and its result is
+	// discarded if ctx is done in the meantime.
+	var output bytes.Buffer
+	if err := c.markdown.Convert(body, &output); err != nil {
+		return fmt.Errorf("%s: %v", BuiltinConverter, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %v", BuiltinConverter, err)
+	}
+
+	if err := os.WriteFile(outputFilePath, output.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	c.trace("%s: %s => %s", BuiltinConverter, inputFilePath, outputFilePath)
+	return nil
+}
@@ -0,0 +1,98 @@
+package anduril_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+)
+
+// convertMarkdown converts the markdown with the builtin converter, and returns the HTML fragment.
+func convertMarkdown(t *testing.T, ctx context.Context, markdown string) (string, error) {
+	t.Helper()
+
+	dir := t.TempDir()
+	input, output := filepath.Join(dir, "article.md"), filepath.Join(dir, "article.html")
+	if err := os.WriteFile(input, []byte(markdown), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := anduril.NewNativeConverter(t.Logf).ConvertMarkdownToHTML(ctx, input, output); err != nil {
+		if _, statErr := os.Stat(output); !os.IsNotExist(statErr) {
+			t.Fatalf("failed conversion: expected no output, found: %v", statErr)
+		}
+		return "", err
+	}
+	html, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(html), nil
+}
+
+func TestNativeConverterExtensions(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		markdown string
+		expected []string
+	}{
+		{
+			"table",
+			"| Name | Value |\n| :--- | ----: |\n| a | 1 |\n",
+			[]string{"<table>", `<th style="text-align:left">Name</th>`, `<td style="text-align:right">1</td>`},
+		},
+		{
+			"strikethrough",
+			"This is ~~not~~ supported.\n",
+			[]string{"<del>not</del>"},
+		},
+		{
+			"task list",
+			"- [x] done\n- [ ] todo\n",
+			[]string{`<input checked="" disabled="" type="checkbox"> done`, `<input disabled="" type="checkbox"> todo`},
+		},
+		{
+			"autolink",
+			"See https://www.example.com/notes for more.\n",
+			[]string{`<a href="https://www.example.com/notes">https://www.example.com/notes</a>`},
+		},
+		{
+			"footnote",
+			"Text with a note.[^1]\n\n[^1]: The note.\n",
+			[]string{`<sup id="fnref:1"><a href="#fn:1" class="footnote-ref" role="doc-noteref">1</a></sup>`, `<li id="fn:1">`, "The note."},
+		},
+		{
+			"heading",
+			"## Getting started\n",
+			[]string{`<h2 id="getting-started">Getting started</h2>`},
+		},
+		{
+			"front matter",
+			"---\ntitle: Go\ntags: [programming]\n---\n\nBody of Go.\n",
+			[]string{"<p>Body of Go.</p>"},
+		},
+	} {
+		html, err := convertMarkdown(t, context.Background(), test.markdown)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		for _, expected := range test.expected {
+			if !strings.Contains(html, expected) {
+				t.Fatalf("%s: %q not found in:\n%s", test.name, expected, html)
+			}
+		}
+		if strings.Contains(html, "title:") {
+			t.Fatalf("%s: metadata found in:\n%s", test.name, html)
+		}
+	}
+}
+
+func TestNativeConverterCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := convertMarkdown(t, ctx, "# Cancelled\n"); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Fatalf("expected error %v, found: %v", context.Canceled, err)
+	}
+}
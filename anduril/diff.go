@@ -0,0 +1,83 @@
+package anduril
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// DiffSegment is a run of text which is either unchanged, inserted or deleted.
+type DiffSegment struct {
+	Text     string
+	Inserted bool
+	Deleted  bool
+}
+
+// Words and runs of whitespace between them are the units of word-level diffs.
+var wordPattern = regexp.MustCompile(`\s+|\S+`)
+
+// WordDiff returns a word-level diff which transforms text from into text to.
+// Adjacent segments of the same kind are merged.
+func WordDiff(from string, to string) []DiffSegment {
+	// Every distinct word is encoded as a single rune, so that the character-level
+	// algorithm compares whole words.
+	tokens := []string{}
+	codes := make(map[string]rune)
+	encode := func(text string) []rune {
+		words := wordPattern.FindAllString(text, -1)
+		runes := make([]rune, len(words))
+		for i, word := range words {
+			code, found := codes[word]
+			if !found {
+				code = tokenRune(len(tokens))
+				codes[word] = code
+				tokens = append(tokens, word)
+			}
+			runes[i] = code
+		}
+		return runes
+	}
+
+	fromRunes, toRunes := encode(from), encode(to)
+	diffs := diffmatchpatch.New().DiffMainRunes(fromRunes, toRunes, false)
+
+	segments := []DiffSegment{}
+	for _, diff := range diffs {
+		var text strings.Builder
+		for _, code := range diff.Text {
+			text.WriteString(tokens[tokenIndex(code)])
+		}
+		segment := DiffSegment{
+			Text:     text.String(),
+			Inserted: diff.Type == diffmatchpatch.DiffInsert,
+			Deleted:  diff.Type == diffmatchpatch.DiffDelete,
+		}
+		if n := len(segments); n > 0 && segments[n-1].Inserted == segment.Inserted && segments[n-1].Deleted == segment.Deleted {
+			segments[n-1].Text += segment.Text
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	return segments
+}
+
+// Runes in the surrogate range are not valid in strings, and are skipped when encoding tokens.
+const (
+	surrogateMin   = 0xD800
+	surrogateRange = 0x800
+)
+
+func tokenRune(index int) rune {
+	if index < surrogateMin {
+		return rune(index)
+	}
+	return rune(index + surrogateRange)
+}
+
+func tokenIndex(code rune) int {
+	if code < surrogateMin {
+		return int(code)
+	}
+	return int(code) - surrogateRange
+}
@@ -0,0 +1,50 @@
+package anduril_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+)
+
+func TestWordDiff(t *testing.T) {
+	segments := anduril.WordDiff(
+		"A dry suit keeps the diver warm.",
+		"A dry suit keeps the diver warm and dry.",
+	)
+	expected := []anduril.DiffSegment{
+		{Text: "A dry suit keeps the diver "},
+		{Text: "warm.", Deleted: true},
+		{Text: "warm and dry.", Inserted: true},
+	}
+	if len(segments) != len(expected) {
+		t.Fatalf("segments: expected: %+v found: %+v", expected, segments)
+	}
+	for i := range expected {
+		if segments[i] != expected[i] {
+			t.Fatalf("segments[%d]: expected: %+v found: %+v", i, expected[i], segments[i])
+		}
+	}
+}
+
+func TestWordDiffUnchanged(t *testing.T) {
+	segments := anduril.WordDiff("# Go Notes\n\nGenerics.", "# Go Notes\n\nGenerics.")
+	if len(segments) != 1 || segments[0].Inserted || segments[0].Deleted {
+		t.Fatalf("segments: expected a single unchanged segment, found: %+v", segments)
+	}
+}
+
+func TestWordDiffManyWords(t *testing.T) {
+	// More distinct words than there are runes below the surrogate range.
+	var b strings.Builder
+	for i := 0; i < 60000; i++ {
+		fmt.Fprintf(&b, "w%d ", i)
+	}
+	from := b.String()
+	to := from + "end"
+	segments := anduril.WordDiff(from, to)
+	if len(segments) != 2 || segments[0].Text != from || !segments[1].Inserted || segments[1].Text != "end" {
+		t.Fatalf("segments: expected the inserted word at the end, found %d segments", len(segments))
+	}
+}
@@ -1,6 +1,8 @@
 package anduril
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os/exec"
@@ -8,15 +10,47 @@ import (
 	"github.com/cicovic-andrija/anduril/service"
 )
 
+// Executor is a markdown converter which runs pandoc as an external process.
 type Executor struct {
-	trace service.TraceCallback
+	version string
+	trace   service.TraceCallback
 }
 
-func (e *Executor) ConvertMarkdownToHTML(inputFilePath string, outputFilePath string) error {
-	c := exec.Command(service.MarkdownHTMLConverter, "--from", "markdown", "--to", "html5", "--output", outputFilePath, inputFilePath)
+// NewExecutor makes sure pandoc is installed on the system, and determines its version.
+func NewExecutor(trace service.TraceCallback) (*Executor, error) {
+	if err := service.CheckDependency(service.MarkdownHTMLConverter); err != nil {
+		return nil, err
+	}
+
+	output, err := exec.Command(service.MarkdownHTMLConverter, "--version").Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to determine version: %v", service.MarkdownHTMLConverter, err)
+	}
+
+	// The first line of output is in the format "pandoc X.Y.Z".
+	version, _, _ := bytes.Cut(output, []byte("\n"))
+	return &Executor{
+		version: string(bytes.TrimSpace(version)),
+		trace:   trace,
+	}, nil
+}
+
+func (e *Executor) Name() string {
+	return PandocConverter
+}
+
+func (e *Executor) Version() string {
+	return e.version
+}
+
+func (e *Executor) ConvertMarkdownToHTML(ctx context.Context, inputFilePath string, outputFilePath string) error {
+	c := exec.CommandContext(ctx, service.MarkdownHTMLConverter, "--from", "markdown", "--to", "html5", "--output", outputFilePath, inputFilePath)
 	c.Stdout = io.Discard
 	c.Stderr = io.Discard
 	if err := c.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s: %v", service.MarkdownHTMLConverter, ctx.Err())
+		}
 		return fmt.Errorf("%s: %v", service.MarkdownHTMLConverter, err)
 	}
 	e.trace("%s: %s => %s", service.MarkdownHTMLConverter, inputFilePath, outputFilePath)
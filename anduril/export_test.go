@@ -0,0 +1,62 @@
+package anduril
+
+import "net/http"
+
+// Hooks into unexported routines of the web server, used by tests.
+
+// SyncSource syncs the repository of the named source, and builds a new revision
+// if the source has changed.
+func (s *WebServer) SyncSource(name string) error {
+	return s.syncSource(s.generateTraceCallback(RepositoryTag), s.source(name))
+}
+
+// PublishRevision publishes the revision as if it was just built.
+func (s *WebServer) PublishRevision(revision *Revision) {
+	s.buildLock.Lock()
+	defer s.buildLock.Unlock()
+	s.publishRevision(revision, s.generateTraceCallback(RepositoryTag))
+}
+
+// LatestRevision returns the served revision.
+func (s *WebServer) LatestRevision() *Revision {
+	s.revisionLock.RLock()
+	defer s.revisionLock.RUnlock()
+	return s.latestRevision
+}
+
+// LockHistory prevents builds of historical revisions until the returned function is called.
+func (s *WebServer) LockHistory() (unlock func()) {
+	s.historyLock.Lock()
+	return s.historyLock.Unlock
+}
+
+// AdminHandler returns the handler registered for the administration endpoint at path.
+func (s *WebServer) AdminHandler(path string) http.Handler {
+	return s.adminHandlers()[path]
+}
+
+// SyncTriggered reports whether a sync of the named source is pending, and clears the trigger.
+func (s *WebServer) SyncTriggered(name string) bool {
+	select {
+	case <-s.source(name).trigger:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetConverter replaces the markdown converter. Must not be called while a revision is built.
+func (s *WebServer) SetConverter(converter MarkdownConverter) {
+	s.converter = converter
+}
+
+// CleanUpStaleFiles removes compiled files which are not referenced by any revision.
+func (s *WebServer) CleanUpStaleFiles() error {
+	return s.cleanUpStaleFiles(s.generateTraceCallback(CleanupTag))
+}
+
+// ConvertArticles converts articles of the revision to HTML, and returns the number of articles
+// reused from cache, and errors of failed conversions.
+func (s *WebServer) ConvertArticles(revision *Revision) (int, []error) {
+	return s.convertArticles(revision)
+}
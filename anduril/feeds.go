@@ -0,0 +1,360 @@
+package anduril
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Structures and routines for generating syndication feeds (Atom, RSS, JSON Feed) of a revision.
+
+// Feed file names, as they appear in URL paths.
+const (
+	AtomFeedFile = "feed.atom"
+	RSSFeedFile  = "feed.rss"
+	JSONFeedFile = "feed.json"
+)
+
+// Content types of feed documents.
+const (
+	AtomContentType = "application/atom+xml; charset=utf-8"
+	RSSContentType  = "application/rss+xml; charset=utf-8"
+	JSONContentType = "application/feed+json; charset=utf-8"
+)
+
+// Version URL of the implemented JSON Feed specification.
+const JSONFeedVersion = "https://jsonfeed.org/version/1.1"
+
+// Maximum number of entries in a single feed.
+const FeedEntryLimit = 50
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description,omitempty"`
+	Authors     []jsonFeedAuthor `json:"authors,omitempty"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	ContentHTML   string   `json:"content_html"`
+	Summary       string   `json:"summary,omitempty"`
+	DatePublished string   `json:"date_published,omitempty"`
+	DateModified  string   `json:"date_modified,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// feedEntry is a format-independent representation of an article in a feed.
+type feedEntry struct {
+	article *Article
+	url     string
+	updated time.Time
+	content string
+}
+
+// generateFeeds renders all feeds of the revision. It must be called
+// after articles of the revision are converted to HTML.
+func (s *WebServer) generateFeeds(revision *Revision) {
+	revision.Feeds = make(map[string][]byte)
+
+	entries := make(map[string]*feedEntry)
+	for key, article := range revision.Articles {
+		entries[key] = s.newFeedEntry(article)
+	}
+
+	siteTitle := StaticPages["home"].Title
+	siteEntries := collectFeedEntries(entries, revision.Articles, isPublicArticle)
+	s.storeFeed(revision, AtomFeedFile, "/"+AtomFeedFile, s.renderAtomFeed, siteTitle, siteEntries)
+	s.storeFeed(revision, RSSFeedFile, "/"+RSSFeedFile, s.renderRSSFeed, siteTitle, siteEntries)
+
+	// JSON Feed follows the same publishing rules as the website itself.
+	jsonEntries := collectFeedEntries(entries, revision.Articles, s.isPublishable)
+	s.storeFeed(revision, JSONFeedFile, "/"+JSONFeedFile, s.renderJSONFeed, siteTitle, jsonEntries)
+
+	for tag, articles := range revision.Tags {
+		tagged := make(map[string]*Article, len(articles))
+		for _, article := range articles {
+			tagged[article.Key] = article
+		}
+		// Tags with only private articles don't get a feed.
+		if tagEntries := collectFeedEntries(entries, tagged, isPublicArticle); len(tagEntries) > 0 {
+			title := fmt.Sprintf("%s: %s", siteTitle, tag)
+			s.storeFeed(revision, tagFeedPath(tag), tagFeedURLPath(tag), s.renderAtomFeed, title, tagEntries)
+		}
+	}
+}
+
+func (s *WebServer) newFeedEntry(article *Article) *feedEntry {
+	entry := &feedEntry{
+		article: article,
+		url:     s.absoluteURL("/articles/" + article.Key),
+		updated: lastModified(article),
+	}
+
+	content, err := os.ReadFile(s.env.CompiledTemplatePath(compiledHTMLTemplate(article.ContentHash)))
+	if err != nil {
+		s.warn("feed entry for %s will not have content: %v", article.Key, err)
+	} else {
+		entry.content = string(content)
+	}
+
+	return entry
+}
+
+// collectFeedEntries returns entries of the included articles, most recently updated first.
+func collectFeedEntries(entries map[string]*feedEntry, articles map[string]*Article, include func(*Article) bool) []*feedEntry {
+	collected := []*feedEntry{}
+	for key, article := range articles {
+		if entry, found := entries[key]; found && include(article) {
+			collected = append(collected, entry)
+		}
+	}
+
+	sort.Slice(collected, func(i, j int) bool {
+		if !collected[i].updated.Equal(collected[j].updated) {
+			return collected[i].updated.After(collected[j].updated)
+		}
+		return collected[i].article.Key < collected[j].article.Key
+	})
+
+	if len(collected) > FeedEntryLimit {
+		collected = collected[:FeedEntryLimit]
+	}
+	return collected
+}
+
+type feedRenderer func(title string, feedURL string, entries []*feedEntry) ([]byte, error)
+
+// storeFeed renders a feed of the entries, and stores it into the revision under path.
+// The feed is served from urlPath, which is path with escaped segments.
+func (s *WebServer) storeFeed(revision *Revision, path string, urlPath string, render feedRenderer, title string, entries []*feedEntry) {
+	feed, err := render(title, s.absoluteURL(urlPath), entries)
+	if err != nil {
+		s.warn("failed to render feed %s: %v", path, err)
+		return
+	}
+	revision.Feeds[path] = feed
+}
+
+func (s *WebServer) renderAtomFeed(title string, feedURL string, entries []*feedEntry) ([]byte, error) {
+	feed := atomFeed{
+		Title: title,
+		ID:    feedURL,
+		Author: atomPerson{
+			Name: s.settings.SiteAuthor,
+		},
+		Links: []atomLink{
+			{Href: feedURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: s.absoluteURL("/"), Rel: "alternate", Type: "text/html"},
+		},
+	}
+
+	var updated time.Time
+	for _, entry := range entries {
+		if entry.updated.After(updated) {
+			updated = entry.updated
+		}
+
+		atomEntry := atomEntry{
+			Title:   entry.article.Title,
+			ID:      entry.url,
+			Updated: entry.updated.Format(time.RFC3339),
+			Links:   []atomLink{{Href: entry.url, Rel: "alternate", Type: "text/html"}},
+		}
+		if !entry.article.CreatedTime.IsZero() {
+			atomEntry.Published = entry.article.CreatedTime.Format(time.RFC3339)
+		}
+		for _, tag := range entry.article.Tags {
+			atomEntry.Categories = append(atomEntry.Categories, atomCategory{Term: tag})
+		}
+		if entry.article.Comment != "" {
+			atomEntry.Summary = &atomText{Type: "text", Body: entry.article.Comment}
+		}
+		if entry.content != "" {
+			atomEntry.Content = &atomText{Type: "html", Body: entry.content}
+		}
+		feed.Entries = append(feed.Entries, atomEntry)
+	}
+	feed.Updated = updated.Format(time.RFC3339)
+
+	return marshalFeed(feed)
+}
+
+func (s *WebServer) renderRSSFeed(title string, feedURL string, entries []*feedEntry) ([]byte, error) {
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       title,
+			Link:        s.absoluteURL("/"),
+			Description: StaticPages["home"].FooterText,
+		},
+	}
+
+	var updated time.Time
+	for _, entry := range entries {
+		if entry.updated.After(updated) {
+			updated = entry.updated
+		}
+
+		item := rssItem{
+			Title:       entry.article.Title,
+			Link:        entry.url,
+			GUID:        entry.url,
+			Categories:  entry.article.Tags,
+			Description: entry.content,
+		}
+		if !entry.article.CreatedTime.IsZero() {
+			item.PubDate = entry.article.CreatedTime.Format(time.RFC1123Z)
+		}
+		if item.Description == "" {
+			item.Description = entry.article.Comment
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+	if !updated.IsZero() {
+		feed.Channel.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+
+	return marshalFeed(feed)
+}
+
+func (s *WebServer) renderJSONFeed(title string, feedURL string, entries []*feedEntry) ([]byte, error) {
+	feed := jsonFeed{
+		Version:     JSONFeedVersion,
+		Title:       title,
+		HomePageURL: s.absoluteURL("/"),
+		FeedURL:     feedURL,
+		Description: StaticPages["home"].FooterText,
+		Items:       []jsonFeedItem{},
+	}
+	if s.settings.SiteAuthor != "" {
+		feed.Authors = []jsonFeedAuthor{{Name: s.settings.SiteAuthor}}
+	}
+
+	for _, entry := range entries {
+		item := jsonFeedItem{
+			ID:          entry.url,
+			URL:         entry.url,
+			Title:       entry.article.Title,
+			ContentHTML: entry.content,
+			Summary:     entry.article.Comment,
+			Tags:        entry.article.Tags,
+		}
+		if !entry.article.CreatedTime.IsZero() {
+			item.DatePublished = entry.article.CreatedTime.Format(time.RFC3339)
+		}
+		if !entry.article.ModifiedTime.IsZero() {
+			item.DateModified = entry.article.ModifiedTime.Format(time.RFC3339)
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	return json.MarshalIndent(feed, "", "  ")
+}
+
+func marshalFeed(feed interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// tagFeedPath returns the path under which the feed of the tag is stored in a revision,
+// which is matched against unescaped request paths.
+func tagFeedPath(tag string) string {
+	return fmt.Sprintf("tags/%s/%s", tag, AtomFeedFile)
+}
+
+// tagFeedURLPath returns the URL path of the feed of the tag, as linked from pages.
+func tagFeedURLPath(tag string) string {
+	return fmt.Sprintf("/tags/%s/%s", url.PathEscape(tag), AtomFeedFile)
+}
+
+func feedContentType(path string) string {
+	switch {
+	case strings.HasSuffix(path, RSSFeedFile):
+		return RSSContentType
+	case strings.HasSuffix(path, JSONFeedFile):
+		return JSONContentType
+	default:
+		return AtomContentType
+	}
+}
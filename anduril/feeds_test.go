@@ -0,0 +1,341 @@
+package anduril_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+)
+
+const feedSiteURL = "https://www.example.com/notes"
+
+// Parts of Atom and RSS documents checked by tests.
+type (
+	testAtomFeed struct {
+		Title   string          `xml:"title"`
+		ID      string          `xml:"id"`
+		Updated string          `xml:"updated"`
+		Author  string          `xml:"author>name"`
+		Links   []testAtomLink  `xml:"link"`
+		Entries []testAtomEntry `xml:"entry"`
+	}
+	testAtomLink struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	}
+	testAtomEntry struct {
+		Title      string         `xml:"title"`
+		ID         string         `xml:"id"`
+		Updated    string         `xml:"updated"`
+		Published  string         `xml:"published"`
+		Links      []testAtomLink `xml:"link"`
+		Categories []struct {
+			Term string `xml:"term,attr"`
+		} `xml:"category"`
+		Content string `xml:"content"`
+	}
+	testRSSFeed struct {
+		Version string `xml:"version,attr"`
+		Channel struct {
+			Title         string        `xml:"title"`
+			Link          string        `xml:"link"`
+			LastBuildDate string        `xml:"lastBuildDate"`
+			Items         []testRSSItem `xml:"item"`
+		} `xml:"channel"`
+	}
+	testRSSItem struct {
+		Title       string   `xml:"title"`
+		Link        string   `xml:"link"`
+		GUID        string   `xml:"guid"`
+		PubDate     string   `xml:"pubDate"`
+		Categories  []string `xml:"category"`
+		Description string   `xml:"description"`
+	}
+)
+
+// writeDatedArticle writes a data file with the title, dates and tags to the directory.
+func writeDatedArticle(t *testing.T, directory string, fileName string, title string, created string, modified string, tags ...string) {
+	t.Helper()
+
+	content := fmt.Sprintf("---\ntitle: %s\ntags: [%s]\ncreated: %s\n", title, strings.Join(tags, ", "), created)
+	if modified != "" {
+		content += fmt.Sprintf("modified: %s\n", modified)
+	}
+	content += fmt.Sprintf("---\n\nBody of %s.\n", title)
+	if err := os.WriteFile(filepath.Join(directory, fileName), []byte(content), 0644); err != nil {
+		t.Fatalf("write article: %v", err)
+	}
+}
+
+// newFeedTestServer returns a server publishing private articles, with a revision of articles
+// created and modified at different times.
+func newFeedTestServer(t *testing.T, configure func(config *anduril.Config)) *anduril.WebServer {
+	notes := t.TempDir()
+	server := newTestServer(t, t.TempDir(), func(config *anduril.Config) {
+		config.Settings.SiteURL = feedSiteURL + "/"
+		config.Settings.SiteAuthor = "Test Author"
+		config.Settings.PublishPrivateArticles = true
+		config.Repositories = []anduril.Source{newDirectorySource("notes", notes)}
+		if configure != nil {
+			configure(config)
+		}
+	})
+	writeDatedArticle(t, notes, "old.md", "Old", "2023-01-01T10:00:00Z", "", "go")
+	writeDatedArticle(t, notes, "new.md", "New", "2023-03-01T10:00:00Z", "", "misc")
+	writeDatedArticle(t, notes, "updated.md", "Updated", "2022-01-01T10:00:00Z", "2023-06-01T10:00:00Z", "misc", "go")
+	writeDatedArticle(t, notes, "diary.md", "Diary", "2024-01-01T10:00:00Z", "", "go", "diary", "private")
+	syncSources(t, server, "notes")
+	return server
+}
+
+func getFeed(t *testing.T, server *anduril.WebServer, path string, feed interface{}) {
+	t.Helper()
+
+	content := server.LatestRevision().GetFeed(path)
+	if content == nil {
+		t.Fatalf("%s: feed not found", path)
+	}
+	if err := xml.Unmarshal(content, feed); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+}
+
+func TestAtomFeed(t *testing.T) {
+	server := newFeedTestServer(t, nil)
+	feed := testAtomFeed{}
+	getFeed(t, server, anduril.AtomFeedFile, &feed)
+
+	if feed.ID != feedSiteURL+"/feed.atom" || feed.Author != "Test Author" || feed.Updated != "2023-06-01T10:00:00Z" {
+		t.Fatalf("feed: unexpected ID, author or update time: %s %s %s", feed.ID, feed.Author, feed.Updated)
+	}
+	expectedLinks := []testAtomLink{{feedSiteURL + "/feed.atom", "self"}, {feedSiteURL + "/", "alternate"}}
+	if !reflect.DeepEqual(feed.Links, expectedLinks) {
+		t.Fatalf("links: expected: %v found: %v", expectedLinks, feed.Links)
+	}
+
+	// Private articles are left out, and entries are ordered by the time they were last updated.
+	titles := []string{}
+	for _, entry := range feed.Entries {
+		titles = append(titles, entry.Title)
+	}
+	if strings.Join(titles, ",") != "Updated,New,Old" {
+		t.Fatalf("entries: expected: Updated,New,Old found: %s", strings.Join(titles, ","))
+	}
+
+	entry := feed.Entries[0]
+	link := feedSiteURL + "/articles/updated"
+	if entry.ID != link || len(entry.Links) != 1 || entry.Links[0].Href != link {
+		t.Fatalf("entry: expected %s as ID and link, found: %s %v", link, entry.ID, entry.Links)
+	}
+	if entry.Published != "2022-01-01T10:00:00Z" || entry.Updated != "2023-06-01T10:00:00Z" {
+		t.Fatalf("entry: unexpected publish or update time: %s %s", entry.Published, entry.Updated)
+	}
+	if len(entry.Categories) != 2 || entry.Categories[0].Term != "misc" || entry.Categories[1].Term != "go" {
+		t.Fatalf("entry: unexpected categories: %v", entry.Categories)
+	}
+	if !strings.Contains(entry.Content, "<p>Body of Updated.</p>") {
+		t.Fatalf("entry: expected the converted article as content, found: %q", entry.Content)
+	}
+}
+
+func TestRSSFeed(t *testing.T) {
+	server := newFeedTestServer(t, nil)
+	feed := testRSSFeed{}
+	getFeed(t, server, anduril.RSSFeedFile, &feed)
+
+	if feed.Version != "2.0" || feed.Channel.Link != feedSiteURL+"/" {
+		t.Fatalf("feed: unexpected version or link: %s %s", feed.Version, feed.Channel.Link)
+	}
+	if feed.Channel.LastBuildDate != "Thu, 01 Jun 2023 10:00:00 +0000" {
+		t.Fatalf("feed: unexpected last build date: %s", feed.Channel.LastBuildDate)
+	}
+
+	expected := []testRSSItem{
+		{"Updated", feedSiteURL + "/articles/updated", feedSiteURL + "/articles/updated", "Sat, 01 Jan 2022 10:00:00 +0000", []string{"misc", "go"}, "<p>Body of Updated.</p>\n"},
+		{"New", feedSiteURL + "/articles/new", feedSiteURL + "/articles/new", "Wed, 01 Mar 2023 10:00:00 +0000", []string{"misc"}, "<p>Body of New.</p>\n"},
+		{"Old", feedSiteURL + "/articles/old", feedSiteURL + "/articles/old", "Sun, 01 Jan 2023 10:00:00 +0000", []string{"go"}, "<p>Body of Old.</p>\n"},
+	}
+	if !reflect.DeepEqual(feed.Channel.Items, expected) {
+		t.Fatalf("items: expected: %v found: %v", expected, feed.Channel.Items)
+	}
+}
+
+func TestTagFeeds(t *testing.T) {
+	server := newFeedTestServer(t, nil)
+	for _, test := range []struct {
+		tag     string
+		entries string
+	}{
+		{"go", "Updated,Old"},
+		{"misc", "Updated,New"},
+	} {
+		path := "tags/" + test.tag + "/" + anduril.AtomFeedFile
+		feed := testAtomFeed{}
+		getFeed(t, server, path, &feed)
+		if !strings.HasSuffix(feed.Title, ": "+test.tag) || feed.ID != feedSiteURL+"/"+path {
+			t.Fatalf("%s: unexpected title or ID: %s %s", test.tag, feed.Title, feed.ID)
+		}
+		titles := []string{}
+		for _, entry := range feed.Entries {
+			titles = append(titles, entry.Title)
+		}
+		if strings.Join(titles, ",") != test.entries {
+			t.Fatalf("%s: entries: expected: %s found: %s", test.tag, test.entries, strings.Join(titles, ","))
+		}
+	}
+
+	// Tags of only private articles don't have a feed.
+	for _, tag := range []string{"diary", "private"} {
+		if feed := server.LatestRevision().GetFeed("tags/" + tag + "/" + anduril.AtomFeedFile); feed != nil {
+			t.Fatalf("%s: expected no feed", tag)
+		}
+	}
+}
+
+// testJSONFeed is a JSON Feed document, decoded with the field names of the specification.
+type testJSONFeed struct {
+	Version     string `json:"version"`
+	Title       string `json:"title"`
+	HomePageURL string `json:"home_page_url"`
+	FeedURL     string `json:"feed_url"`
+	Authors     []struct {
+		Name string `json:"name"`
+	} `json:"authors"`
+	Items []testJSONFeedItem `json:"items"`
+}
+
+type testJSONFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	ContentHTML   string   `json:"content_html"`
+	DatePublished string   `json:"date_published"`
+	DateModified  string   `json:"date_modified"`
+	Tags          []string `json:"tags"`
+}
+
+func TestJSONFeed(t *testing.T) {
+	server := newFeedTestServer(t, nil)
+	response := httptest.NewRecorder()
+	server.FeedHandlerLocked(response, httptest.NewRequest(http.MethodGet, "/"+anduril.JSONFeedFile, nil))
+	if response.Code != http.StatusOK || response.Header().Get("Content-Type") != anduril.JSONContentType {
+		t.Fatalf("feed: expected: %d %s found: %d %s", http.StatusOK, anduril.JSONContentType, response.Code, response.Header().Get("Content-Type"))
+	}
+
+	feed := testJSONFeed{}
+	if err := json.Unmarshal(response.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("feed: %v", err)
+	}
+	if feed.Version != "https://jsonfeed.org/version/1.1" {
+		t.Fatalf("version: expected: https://jsonfeed.org/version/1.1 found: %s", feed.Version)
+	}
+	if feed.HomePageURL != feedSiteURL+"/" || feed.FeedURL != feedSiteURL+"/feed.json" {
+		t.Fatalf("feed: unexpected home page or feed URL: %s %s", feed.HomePageURL, feed.FeedURL)
+	}
+	if len(feed.Authors) != 1 || feed.Authors[0].Name != "Test Author" {
+		t.Fatalf("authors: expected: Test Author found: %v", feed.Authors)
+	}
+
+	// Private articles are published, the same way as on the website.
+	expected := []testJSONFeedItem{
+		{feedSiteURL + "/articles/diary", feedSiteURL + "/articles/diary", "Diary", "<p>Body of Diary.</p>\n", "2024-01-01T10:00:00Z", "", []string{"go", "diary", "private"}},
+		{feedSiteURL + "/articles/updated", feedSiteURL + "/articles/updated", "Updated", "<p>Body of Updated.</p>\n", "2022-01-01T10:00:00Z", "2023-06-01T10:00:00Z", []string{"misc", "go"}},
+		{feedSiteURL + "/articles/new", feedSiteURL + "/articles/new", "New", "<p>Body of New.</p>\n", "2023-03-01T10:00:00Z", "", []string{"misc"}},
+		{feedSiteURL + "/articles/old", feedSiteURL + "/articles/old", "Old", "<p>Body of Old.</p>\n", "2023-01-01T10:00:00Z", "", []string{"go"}},
+	}
+	if !reflect.DeepEqual(feed.Items, expected) {
+		t.Fatalf("items: expected: %v found: %v", expected, feed.Items)
+	}
+}
+
+func TestJSONFeedPrivateArticles(t *testing.T) {
+	for _, test := range []struct {
+		publishPrivate bool
+		items          string
+	}{
+		{true, "Diary,Updated,New,Old"},
+		{false, "Updated,New,Old"},
+	} {
+		server := newFeedTestServer(t, func(config *anduril.Config) {
+			config.Settings.PublishPrivateArticles = test.publishPrivate
+		})
+		feed := testJSONFeed{}
+		if err := json.Unmarshal(server.LatestRevision().GetFeed(anduril.JSONFeedFile), &feed); err != nil {
+			t.Fatalf("%v: feed: %v", test.publishPrivate, err)
+		}
+		titles := []string{}
+		for _, item := range feed.Items {
+			titles = append(titles, item.Title)
+		}
+		if strings.Join(titles, ",") != test.items {
+			t.Fatalf("%v: items: expected: %s found: %s", test.publishPrivate, test.items, strings.Join(titles, ","))
+		}
+	}
+}
+
+// tagFeedLink matches the link to the feed of the tag in a tag page.
+var tagFeedLink = regexp.MustCompile(`<link href="([^"]*)" rel="alternate" type="application/atom\+xml" title="The L-Archive: `)
+
+func TestTagFeedLinks(t *testing.T) {
+	notes := t.TempDir()
+	server := newTestServer(t, t.TempDir(), func(config *anduril.Config) {
+		config.Settings.PublishPrivateArticles = true
+		config.Repositories = []anduril.Source{newDirectorySource("notes", notes)}
+	})
+	writeArticle(t, notes, "csharp.md", "C#", "c#", "languages")
+	writeArticle(t, notes, "diary.md", "Diary", "diary", "private")
+	syncSources(t, server, "notes")
+
+	for _, test := range []struct {
+		tag  string
+		link string
+	}{
+		{"c#", "/tags/c%23/feed.atom"},
+		{"languages", "/tags/languages/feed.atom"},
+		// Tags with only private articles don't have a feed, and their pages don't link to one.
+		{"diary", ""},
+		{"private", ""},
+	} {
+		page := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/tags/", nil)
+		request.URL.Path = test.tag
+		server.TagHandlerLocked(page, request)
+
+		link := ""
+		if match := tagFeedLink.FindStringSubmatch(page.Body.String()); match != nil {
+			link = match[1]
+		}
+		if link != test.link {
+			t.Fatalf("%s: feed link: expected: %q found: %q", test.tag, test.link, link)
+		}
+		if link == "" {
+			continue
+		}
+
+		// The link leads to the feed of the tag.
+		u, err := url.Parse(link)
+		if err != nil {
+			t.Fatalf("%s: feed link: %v", test.tag, err)
+		}
+		feed := httptest.NewRecorder()
+		request = httptest.NewRequest(http.MethodGet, link, nil)
+		request.URL.Path = strings.TrimSuffix(strings.TrimPrefix(u.Path, "/tags/"), "/"+anduril.AtomFeedFile)
+		server.TagFeedHandlerLocked(feed, request)
+		if feed.Code != http.StatusOK || feed.Header().Get("Content-Type") != anduril.AtomContentType {
+			t.Fatalf("%s: feed: expected: %d %s found: %d %s", test.tag, http.StatusOK, anduril.AtomContentType, feed.Code, feed.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(feed.Body.String(), "<id>"+testSiteURL+link+"</id>") {
+			t.Fatalf("%s: feed: expected the escaped feed URL as its ID in:\n%s", test.tag, feed.Body.String())
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package anduril
 
 import (
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/cicovic-andrija/libgo/https"
 )
@@ -29,7 +33,16 @@ func (s *WebServer) ArticleRootHandlerLocked(w http.ResponseWriter, r *http.Requ
 		groupArticlesBy = "type"
 	}
 
-	err := s.renderArticleList(w, s.latestRevision, groupArticlesBy)
+	err := s.serveCachedPage(
+		w,
+		r,
+		s.latestRevision,
+		"articles?group-by="+groupArticlesBy,
+		s.latestRevision.LastModified,
+		func(w io.Writer) error {
+			return s.renderArticleList(w, s.latestRevision, groupArticlesBy)
+		},
+	)
 	if err != nil {
 		s.warn("failed to render list of all articles: %v", err)
 	}
@@ -41,7 +54,17 @@ func (s *WebServer) ArticleHandlerLocked(w http.ResponseWriter, r *http.Request)
 	if article == nil {
 		panic(s.error("impossible server state: WebServer.ArticleHandlerLocked: article must exist but not found: key: %s", key))
 	}
-	err := s.renderArticle(w, article, s.latestRevision)
+	err := s.serveCachedPage(
+		w,
+		r,
+		s.latestRevision,
+		"articles/"+key,
+		// The page also lists tags and articles of the revision.
+		latest(lastModified(article), s.latestRevision.LastModified),
+		func(w io.Writer) error {
+			return s.renderArticle(w, article, s.latestRevision)
+		},
+	)
 	if err != nil {
 		s.warn("failed to render article: %v", err)
 	}
@@ -53,7 +76,16 @@ func (s *WebServer) TagRootHandlerLocked(w http.ResponseWriter, r *http.Request)
 	if articles == nil {
 		panic(s.error("impossible server state: WebServer.TagRootHandlerLocked: articles must exist for tag but not found: tag: %s", tag))
 	}
-	err := s.renderArticleListForTag(w, tag, articles, s.latestRevision)
+	err := s.serveCachedPage(
+		w,
+		r,
+		s.latestRevision,
+		"tags",
+		latest(latestModified(articles), s.latestRevision.LastModified),
+		func(w io.Writer) error {
+			return s.renderArticleListForTag(w, tag, articles, s.latestRevision)
+		},
+	)
 	if err != nil {
 		s.warn("failed to render list of all articles for tag %q: %v", tag, err)
 	}
@@ -65,12 +97,106 @@ func (s *WebServer) TagHandlerLocked(w http.ResponseWriter, r *http.Request) {
 	if articles == nil {
 		panic(s.error("impossible server state: WebServer.TagHandlerLocked: articles must exist for tag but not found: tag: %s", tag))
 	}
-	err := s.renderArticleListForTag(w, tag, articles, s.latestRevision)
+	err := s.serveCachedPage(
+		w,
+		r,
+		s.latestRevision,
+		"tags/"+tag,
+		latest(latestModified(articles), s.latestRevision.LastModified),
+		func(w io.Writer) error {
+			return s.renderArticleListForTag(w, tag, articles, s.latestRevision)
+		},
+	)
 	if err != nil {
 		s.warn("failed to render list of all articles for tag %q: %v", tag, err)
 	}
 }
 
+func (s *WebServer) ChangesHandlerLocked(w http.ResponseWriter, r *http.Request) {
+	lastModified := s.latestRevision.BuiltAt
+	if len(s.latestRevision.Changes) > 0 {
+		lastModified = s.latestRevision.Changes[0].Time
+	}
+	err := s.serveCachedPage(
+		w,
+		r,
+		s.latestRevision,
+		"changes",
+		lastModified,
+		func(w io.Writer) error {
+			return s.renderChanges(w, s.latestRevision)
+		},
+	)
+	if err != nil {
+		s.warn("failed to render list of recent changes: %v", err)
+	}
+}
+
+func (s *WebServer) SearchHandlerLocked(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get(SearchQueryParam))
+	results := s.latestRevision.Index.Search(query)
+	err := s.renderSearchResults(w, query, results, s.latestRevision)
+	if err != nil {
+		s.warn("failed to render search results for query %q: %v", query, err)
+	}
+}
+
+func (s *WebServer) SearchSuggestionHandlerLocked(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// The limit requested by the client can only lower the configured limit.
+	limit := s.settings.SearchSuggestionLimit
+	if requested, err := strconv.Atoi(query.Get("limit")); err == nil && requested > 0 && requested < limit {
+		limit = requested
+	}
+
+	suggestions := s.latestRevision.Index.Suggest(query.Get("q"), limit)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(suggestions); err != nil {
+		s.warn("failed to encode search suggestions: %v", err)
+	}
+}
+
+func (s *WebServer) FeedHandlerLocked(w http.ResponseWriter, r *http.Request) {
+	s.serveFeed(w, r, strings.TrimPrefix(r.URL.Path, "/"))
+}
+
+func (s *WebServer) TagFeedHandlerLocked(w http.ResponseWriter, r *http.Request) {
+	s.serveFeed(w, r, tagFeedPath(r.URL.Path))
+}
+
+func (s *WebServer) serveFeed(w http.ResponseWriter, r *http.Request, path string) {
+	feed := s.latestRevision.GetFeed(path)
+	if feed == nil {
+		s.PageNotFoundHandler(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", feedContentType(path))
+	if _, err := w.Write(feed); err != nil {
+		s.warn("failed to write feed %s: %v", path, err)
+	}
+}
+
+func (s *WebServer) SitemapHandlerLocked(w http.ResponseWriter, r *http.Request) {
+	sitemap, err := s.renderSitemap(s.latestRevision)
+	if err != nil {
+		s.warn("failed to render sitemap: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	if _, err := w.Write(sitemap); err != nil {
+		s.warn("failed to write sitemap: %v", err)
+	}
+}
+
+func (s *WebServer) RobotsHandlerLocked(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := w.Write(s.renderRobots()); err != nil {
+		s.warn("failed to write robots.txt: %v", err)
+	}
+}
+
 func (s *WebServer) StaticPageHandler(w http.ResponseWriter, r *http.Request) {
 	key := r.URL.Path
 	page, found := StaticPages[key]
@@ -93,17 +219,17 @@ func (s *WebServer) PageNotFoundHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (s *WebServer) registerHandlers() {
-	s.httpsServer.Handle(
+	s.handle(
 		"/",
 		http.HandlerFunc(s.RootHandler),
 	)
 
-	s.httpsServer.Handle(
+	s.handle(
 		"/home",
 		s.StaticPageRequestHandler(),
 	)
 
-	s.httpsServer.Handle(
+	s.handle(
 		"/tags",
 		https.Adapt(
 			http.HandlerFunc(s.TagRootHandlerLocked),
@@ -111,17 +237,24 @@ func (s *WebServer) registerHandlers() {
 		),
 	)
 
-	s.httpsServer.Handle(
+	s.handle(
 		"/tags/",
 		https.Adapt(
 			http.HandlerFunc(s.TagHandlerLocked),
 			s.FindAndReadLockRevision(TagObject),
+			RouteSuffix(
+				"/"+AtomFeedFile,
+				https.Adapt(
+					http.HandlerFunc(s.TagFeedHandlerLocked),
+					s.FindAndReadLockRevision(TagObject),
+				),
+			),
 			https.StripPrefix("/tags/"),
 			https.RedirectRootToParentTree,
 		),
 	)
 
-	s.httpsServer.Handle(
+	s.handle(
 		"/articles",
 		https.Adapt(
 			http.HandlerFunc(s.ArticleRootHandlerLocked),
@@ -129,28 +262,115 @@ func (s *WebServer) registerHandlers() {
 		),
 	)
 
-	s.httpsServer.Handle(
+	s.handle(
 		"/articles/",
 		https.Adapt(
 			http.HandlerFunc(s.ArticleHandlerLocked),
 			s.FindAndReadLockRevision(ArticleObject),
+			RouteSuffix(
+				ArticleHistorySuffix,
+				http.HandlerFunc(s.ArticleHistoryHandler),
+			),
 			https.StripPrefix("/articles/"),
 			https.RedirectRootToParentTree,
 		),
 	)
 
-	s.httpsServer.Handle(
+	s.handle(
+		TimeTravelPathPrefix,
+		https.Adapt(
+			http.HandlerFunc(s.TimeTravelHandler),
+			https.StripPrefix(TimeTravelPathPrefix),
+		),
+	)
+
+	s.handle(
+		ChangesPath,
+		https.Adapt(
+			http.HandlerFunc(s.ChangesHandlerLocked),
+			s.ReadLockRevision,
+		),
+	)
+
+	s.handle(
+		"/"+ChangesFeedPath,
+		https.Adapt(
+			http.HandlerFunc(s.FeedHandlerLocked),
+			s.ReadLockRevision,
+		),
+	)
+
+	s.handle(
 		"/about",
 		s.StaticPageRequestHandler(),
 	)
 
-	s.httpsServer.Handle(
+	s.handle(
 		"/search",
-		s.StaticPageRequestHandler(),
+		https.Adapt(
+			http.HandlerFunc(s.SearchHandlerLocked),
+			s.ReadLockRevision,
+		),
 	)
 
-	s.httpsServer.Handle(
+	s.handle(
+		"/api/search/suggest",
+		https.Adapt(
+			http.HandlerFunc(s.SearchSuggestionHandlerLocked),
+			s.ReadLockRevision,
+		),
+	)
+
+	s.handle(
+		"/"+AtomFeedFile,
+		https.Adapt(
+			http.HandlerFunc(s.FeedHandlerLocked),
+			s.ReadLockRevision,
+		),
+	)
+
+	s.handle(
+		"/"+RSSFeedFile,
+		https.Adapt(
+			http.HandlerFunc(s.FeedHandlerLocked),
+			s.ReadLockRevision,
+		),
+	)
+
+	s.handle(
+		"/"+JSONFeedFile,
+		https.Adapt(
+			http.HandlerFunc(s.FeedHandlerLocked),
+			s.ReadLockRevision,
+		),
+	)
+
+	s.handle(
+		"/"+SitemapFile,
+		https.Adapt(
+			http.HandlerFunc(s.SitemapHandlerLocked),
+			s.ReadLockRevision,
+		),
+	)
+
+	s.handle(
+		"/"+RobotsFile,
+		https.Adapt(
+			http.HandlerFunc(s.RobotsHandlerLocked),
+			s.ReadLockRevision,
+		),
+	)
+
+	s.handle(
 		"/look-and-feel",
 		s.StaticPageRequestHandler(),
 	)
+
+	// The webhook endpoint is the only one accepting requests other than GET.
+	if s.settings.WebhookSecret != "" {
+		s.httpsServer.Handle(
+			GitWebhookPath,
+			http.HandlerFunc(s.GitWebhookHandler),
+		)
+	}
 }
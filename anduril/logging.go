@@ -13,7 +13,9 @@ const (
 	MarkdownProcessorTag TraceTag = "MarkdownProcessor"
 	RepositoryTag        TraceTag = "Repository"
 	ExecutorTag          TraceTag = "Executor"
+	ConverterTag         TraceTag = "Converter"
 	CleanupTag           TraceTag = "Cleanup"
+	AdminTag             TraceTag = "Admin"
 )
 
 func (s *WebServer) log(format string, v ...interface{}) {
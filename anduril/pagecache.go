@@ -0,0 +1,229 @@
+package anduril
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cicovic-andrija/anduril/service"
+)
+
+// PageCache holds fully rendered pages of a single revision, keyed by page key.
+// The cache belongs to the revision, and is dropped together with it. Pages are
+// rendered with templates of a single version, and are dropped when it changes.
+type PageCache struct {
+	lock    *sync.RWMutex
+	version string
+	pages   map[string]*CachedPage
+}
+
+// CachedPage is a rendered page with validators for conditional requests.
+// Compressed variants of the page are created on first use and cached with the page.
+type CachedPage struct {
+	Body         []byte
+	ETag         string
+	LastModified time.Time
+	encodedLock  *sync.Mutex
+	encoded      map[string][]byte
+}
+
+// Encoded returns the page body compressed with the encoding.
+func (p *CachedPage) Encoded(encoding string) ([]byte, error) {
+	p.encodedLock.Lock()
+	defer p.encodedLock.Unlock()
+	if body, found := p.encoded[encoding]; found {
+		return body, nil
+	}
+	body, err := encode(encoding, p.Body, true /* best */)
+	if err != nil {
+		return nil, err
+	}
+	p.encoded[encoding] = body
+	return body, nil
+}
+
+// VariantETag returns the entity tag of the page compressed with the encoding;
+// different representations of the same page must have different strong entity tags.
+func (p *CachedPage) VariantETag(encoding string) string {
+	if encoding == "" {
+		return p.ETag
+	}
+	return strings.TrimSuffix(p.ETag, `"`) + "-" + encoding + `"`
+}
+
+func NewPageCache() *PageCache {
+	return &PageCache{
+		lock:  &sync.RWMutex{},
+		pages: make(map[string]*CachedPage),
+	}
+}
+
+// Get returns the cached page for key rendered with templates of the version, or calls
+// render and caches the result. Pages rendered with templates of other versions are dropped.
+func (c *PageCache) Get(key string, version string, render func() (*CachedPage, error)) (*CachedPage, error) {
+	c.lock.RLock()
+	page, found := c.pages[key]
+	current := c.version == version
+	c.lock.RUnlock()
+	if found && current {
+		return page, nil
+	}
+
+	page, err := render()
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	if c.version != version {
+		c.version = version
+		c.pages = make(map[string]*CachedPage)
+	}
+	c.pages[key] = page
+	c.lock.Unlock()
+	return page, nil
+}
+
+// serveCachedPage writes the page identified by key from the page cache of the revision,
+// rendering and caching it first if needed. Conditional requests are answered with
+// 304 Not Modified when the client already has the current version of the page.
+// Pages are not cached when templates are configured to be reloaded on every request.
+func (s *WebServer) serveCachedPage(w http.ResponseWriter, r *http.Request, revision *Revision, key string, lastModified time.Time, render func(io.Writer) error) error {
+	// Templates are parsed again when their files change on disk, so the version of the
+	// templates is checked on every request, and cached pages are rendered again when it changes.
+	version, err := templateVersion(s.env.TemplatePath(""))
+	if err != nil {
+		s.warn("failed to determine version of templates: %v", err)
+		version = service.Version + "+" + service.Build
+	}
+
+	renderPage := func() (*CachedPage, error) {
+		var body bytes.Buffer
+		if err := render(&body); err != nil {
+			return nil, err
+		}
+		// Templates may change between requests when they are reloaded,
+		// so the entity tag is derived from the rendered page instead.
+		version := version
+		if s.settings.ReloadTemplates {
+			hash := sha256.Sum256(body.Bytes())
+			version = hex.EncodeToString(hash[:])
+		}
+		return &CachedPage{
+			Body:         body.Bytes(),
+			ETag:         pageETag(version, revision.Hash, key),
+			LastModified: lastModified,
+			encodedLock:  &sync.Mutex{},
+			encoded:      make(map[string][]byte),
+		}, nil
+	}
+
+	var page *CachedPage
+	if s.settings.ReloadTemplates {
+		page, err = renderPage()
+	} else {
+		page, err = revision.Pages.Get(key, version, renderPage)
+	}
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+
+	// Serve the compressed variant of the page from the cache, if the client supports it.
+	body, encoding := page.Body, negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	if encoding != "" {
+		if body, err = page.Encoded(encoding); err != nil {
+			s.warn("failed to compress page %s: %v", key, err)
+			body, encoding = page.Body, ""
+		}
+	}
+	etag := page.VariantETag(encoding)
+
+	header := w.Header()
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("ETag", etag)
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+	if !page.LastModified.IsZero() {
+		header.Set("Last-Modified", page.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if page.isNotModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	_, err = w.Write(body)
+	return err
+}
+
+// isNotModified evaluates conditional request headers against the page validators.
+// If-Modified-Since is considered only when If-None-Match is not present.
+func (p *CachedPage) isNotModified(r *http.Request, etag string) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagListContains(ifNoneMatch, etag)
+	}
+
+	ifModifiedSince := r.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || p.LastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	return !p.LastModified.Truncate(time.Second).After(since)
+}
+
+// etagListContains reports whether the value of an If-None-Match header matches the ETag,
+// using the weak comparison function.
+func etagListContains(list string, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// pageETag returns a strong entity tag for the page identified by key within the revision,
+// rendered with templates of the version.
+func pageETag(templateVersion string, revisionHash string, key string) string {
+	hash := sha256.Sum256([]byte(templateVersion + "\x00" + revisionHash + "\x00" + key))
+	return `"` + hex.EncodeToString(hash[:10]) + `"`
+}
+
+// templateVersion returns a hash of the server build and of the names, sizes and modification
+// times of the template files, so that entity tags of pages change when the server is upgraded
+// or its templates are modified.
+func templateVersion(templatesDirectory string) (string, error) {
+	hash := sha256.New()
+	io.WriteString(hash, service.Version+"\x00"+service.Build+"\x00")
+
+	entries, err := os.ReadDir(templatesDirectory)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		info, err := os.Stat(filepath.Join(templatesDirectory, entry.Name()))
+		if err != nil {
+			return "", err
+		}
+		if info.IsDir() {
+			continue
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00%d\n", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,225 @@
+package anduril_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/cicovic-andrija/anduril/anduril"
+)
+
+// newPageTestServer returns a server with a single article, go, published from the notes directory.
+func newPageTestServer(t *testing.T, wd string) (server *anduril.WebServer, notes string) {
+	notes = t.TempDir()
+	server = newTestServer(t, wd, func(config *anduril.Config) {
+		config.Repositories = []anduril.Source{newDirectorySource("notes", notes)}
+	})
+	writeArticle(t, notes, "Go.md", "Go", "programming")
+	syncSources(t, server, "notes")
+	return
+}
+
+// getArticle serves the article with the request headers.
+func getArticle(server *anduril.WebServer, key string, header map[string]string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "https://localhost/articles/"+key, nil)
+	request.URL.Path = key
+	for name, value := range header {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	server.ArticleHandlerLocked(recorder, request)
+	return recorder
+}
+
+// copyTemplates replaces the templates linked into the working directory with a copy, which can be modified.
+func copyTemplates(t *testing.T, wd string) string {
+	t.Helper()
+
+	templates := filepath.Join(wd, "data", "templates")
+	source, err := filepath.EvalSymlinks(templates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Remove(templates); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mkdirAll(t, templates)
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(source, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(templates, entry.Name()), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return templates
+}
+
+func TestCachedPageTemplateChange(t *testing.T) {
+	wd := t.TempDir()
+	server, _ := newPageTestServer(t, wd)
+	templates := copyTemplates(t, wd)
+
+	before := getArticle(server, "go", nil)
+	if before.Code != http.StatusOK {
+		t.Fatalf("expected: %d found: %d", http.StatusOK, before.Code)
+	}
+	etag := before.Header().Get("ETag")
+
+	// Pages rendered with the previous version of the templates are neither served nor validated.
+	pageTemplate := filepath.Join(templates, "page-v2.html")
+	file, err := os.OpenFile(pageTemplate, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.WriteString("<p>edited template</p>\n"); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	modified := time.Now().Add(time.Minute)
+	if err = os.Chtimes(pageTemplate, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	after := getArticle(server, "go", map[string]string{"If-None-Match": etag})
+	if after.Code != http.StatusOK {
+		t.Fatalf("edited template: expected: %d found: %d", http.StatusOK, after.Code)
+	}
+	if !strings.Contains(after.Body.String(), "<p>edited template</p>") {
+		t.Fatalf("edited template: page rendered with the previous template")
+	}
+	if after.Header().Get("ETag") == etag {
+		t.Fatalf("edited template: ETag unchanged: %s", etag)
+	}
+}
+
+func TestArticleLastModifiedByRevision(t *testing.T) {
+	server, notes := newPageTestServer(t, t.TempDir())
+
+	// Another article, modified after go, changes the lists of articles shown on the page of go.
+	content := "---\ntitle: Rust\ntags: [programming]\ncreated: 2024-06-01T10:00:00Z\n---\n\nBody of Rust.\n"
+	if err := os.WriteFile(filepath.Join(notes, "Rust.md"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	syncSources(t, server, "notes")
+
+	response := getArticle(server, "go", map[string]string{"If-Modified-Since": "Tue, 03 Jan 2023 00:00:00 GMT"})
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected: %d found: %d", http.StatusOK, response.Code)
+	}
+	if lastModified := response.Header().Get("Last-Modified"); lastModified != "Sat, 01 Jun 2024 10:00:00 GMT" {
+		t.Fatalf("Last-Modified: expected: %s found: %s", "Sat, 01 Jun 2024 10:00:00 GMT", lastModified)
+	}
+}
+
+func TestCachedPageConditionalRequests(t *testing.T) {
+	server, _ := newPageTestServer(t, t.TempDir())
+
+	response := getArticle(server, "go", nil)
+	etag := response.Header().Get("ETag")
+	if response.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) {
+		t.Fatalf("expected: %d with a strong ETag found: %d %q", http.StatusOK, response.Code, etag)
+	}
+	lastModified := response.Header().Get("Last-Modified")
+	if lastModified != "Mon, 02 Jan 2023 15:04:05 GMT" {
+		t.Fatalf("Last-Modified: expected: Mon, 02 Jan 2023 15:04:05 GMT found: %s", lastModified)
+	}
+	if again := getArticle(server, "go", nil); again.Header().Get("ETag") != etag {
+		t.Fatalf("ETag of the cached page: expected: %s found: %s", etag, again.Header().Get("ETag"))
+	}
+
+	for _, test := range []struct {
+		header map[string]string
+		code   int
+	}{
+		{map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+		{map[string]string{"If-Modified-Since": "Sun, 01 Jan 2023 00:00:00 GMT"}, http.StatusOK},
+		{map[string]string{"If-Modified-Since": "not a date"}, http.StatusOK},
+		// If-Modified-Since is ignored when If-None-Match is present.
+		{map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}, http.StatusOK},
+	} {
+		response := getArticle(server, "go", test.header)
+		if response.Code != test.code {
+			t.Fatalf("%v: expected: %d found: %d", test.header, test.code, response.Code)
+		}
+		if test.code == http.StatusNotModified && response.Body.Len() != 0 {
+			t.Fatalf("%v: expected an empty body", test.header)
+		}
+	}
+}
+
+func TestCachedPageETagChangesWithRevision(t *testing.T) {
+	server, notes := newPageTestServer(t, t.TempDir())
+	etag := getArticle(server, "go", nil).Header().Get("ETag")
+
+	writeArticle(t, notes, "Rust.md", "Rust", "programming")
+	syncSources(t, server, "notes")
+
+	response := getArticle(server, "go", map[string]string{"If-None-Match": etag})
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected: %d found: %d", http.StatusOK, response.Code)
+	}
+	if response.Header().Get("ETag") == etag {
+		t.Fatalf("ETag unchanged in a new revision: %s", etag)
+	}
+}
+
+func TestCachedPageEncodings(t *testing.T) {
+	server, _ := newPageTestServer(t, t.TempDir())
+	identity := getArticle(server, "go", nil)
+	if identity.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("identity: unexpected Content-Encoding: %s", identity.Header().Get("Content-Encoding"))
+	}
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		anduril.GzipEncoding:   func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		anduril.BrotliEncoding: func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+	}
+	etags := map[string]bool{identity.Header().Get("ETag"): true}
+	for encoding, decoder := range decoders {
+		// The compressed variant is cached with the page, and served the same way every time.
+		for i := 0; i < 2; i++ {
+			response := getArticle(server, "go", map[string]string{"Accept-Encoding": encoding})
+			if found := response.Header().Get("Content-Encoding"); found != encoding {
+				t.Fatalf("%s: Content-Encoding: expected: %s found: %s", encoding, encoding, found)
+			}
+			reader, err := decoder(bytes.NewReader(response.Body.Bytes()))
+			if err != nil {
+				t.Fatalf("%s: %v", encoding, err)
+			}
+			body, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("%s: %v", encoding, err)
+			}
+			if !bytes.Equal(body, identity.Body.Bytes()) {
+				t.Fatalf("%s: decoded body differs from the identity body", encoding)
+			}
+
+			etag := response.Header().Get("ETag")
+			if i == 0 && etags[etag] {
+				t.Fatalf("%s: ETag %s is not unique to the variant", encoding, etag)
+			}
+			etags[etag] = true
+			if notModified := getArticle(server, "go", map[string]string{"Accept-Encoding": encoding, "If-None-Match": etag}); notModified.Code != http.StatusNotModified {
+				t.Fatalf("%s: expected: %d found: %d", encoding, http.StatusNotModified, notModified.Code)
+			}
+		}
+	}
+}
@@ -23,10 +23,25 @@ type Page struct {
 	HighlightedTags   []string
 	Articles          []*Article
 	ArticleGroups     []ArticleGroup
+	SearchQuery       string
+	SearchResults     []SearchResult
 	HeaderText        string
 	FooterText        string
+	FeedPath          string
+	LinkPrefix        string
+	Historical        *HistoricalNotice
+	HistoryPath       string
+	History           *ArticleHistory
+	Changes           []ArticleChange
 	contentTemplate   string
 	isCompiledContent bool
+	revisionHash      string
+}
+
+// HistoricalNotice marks a page rendered from a past revision.
+type HistoricalNotice struct {
+	RevisionHash string
+	CurrentPath  string
 }
 
 type Sidebar struct {
@@ -49,11 +64,6 @@ var (
 			Title:      "About",
 			FooterText: "Made by Andrija Cicović, 2023.",
 		},
-		"search": {
-			Key:        "search",
-			Title:      "Search Results",
-			FooterText: "You can use the sidebar to explore the website.",
-		},
 		"404": {
 			Key:        "404",
 			Title:      "Not Found",
@@ -77,21 +87,30 @@ func (p *Page) IsHighlighted(tag string) bool {
 }
 
 func (s *WebServer) renderArticle(w io.Writer, article *Article, revision *Revision) error {
+	page := articlePage(article, revision)
+	if s.articleHistorySource(article) != nil {
+		page.HistoryPath = "/articles/" + article.Key + ArticleHistorySuffix
+	}
+	return s.renderPage(w, page)
+}
+
+func articlePage(article *Article, revision *Revision) *Page {
 	footerText := fmt.Sprintf("Last updated on %s", article.ModifiedTime.Format("January 2 2006."))
 	if article.Comment != "" {
 		footerText = article.Comment
 	}
 
-	return s.renderPage(w, &Page{
+	return &Page{
 		Key:               article.Key,
 		Title:             article.Title,
 		Tags:              revision.SortedTags,
 		HighlightedTags:   append([]string{}, article.Tags...),
 		HeaderText:        fmt.Sprintf("%s | %s", article.Type, article.CreatedTime.Format("January 2 2006.")),
 		FooterText:        footerText,
-		contentTemplate:   compiledHTMLTemplate(article.Key, revision.Hash),
+		contentTemplate:   compiledHTMLTemplate(article.ContentHash),
 		isCompiledContent: true,
-	})
+		revisionHash:      revision.Hash,
+	}
 }
 
 func (s *WebServer) renderArticleList(w io.Writer, revision *Revision, groupBy string) error {
@@ -118,11 +137,21 @@ func (s *WebServer) renderArticleList(w io.Writer, revision *Revision, groupBy s
 		Tags:          revision.SortedTags,
 		ArticleGroups: articleGroups,
 		FooterText:    fmt.Sprintf("There are %d articles listed.", len(revision.Articles)),
+		revisionHash:  revision.Hash,
 	})
 }
 
 func (s *WebServer) renderArticleListForTag(w io.Writer, tag string, articles []*Article, revision *Revision) error {
-	return s.renderPage(w, &Page{
+	return s.renderPage(w, tagPage(tag, articles, revision))
+}
+
+func tagPage(tag string, articles []*Article, revision *Revision) *Page {
+	// Tags with only private articles, and tags of historical revisions, don't have a feed.
+	feedPath := ""
+	if revision.GetFeed(tagFeedPath(tag)) != nil {
+		feedPath = tagFeedURLPath(tag)
+	}
+	return &Page{
 		Key:   tag,
 		Title: tag,
 		Sidebar: Sidebar{
@@ -132,36 +161,102 @@ func (s *WebServer) renderArticleListForTag(w io.Writer, tag string, articles []
 		HighlightedTags: []string{tag},
 		Articles:        articles,
 		FooterText:      fmt.Sprintf("There are %d articles listed.", len(articles)),
+		FeedPath:        feedPath,
 		contentTemplate: htmlTemplate("articles"),
+		revisionHash:    revision.Hash,
+	}
+}
+
+func historyPage(article *Article, revision *Revision, history *ArticleHistory) *Page {
+	return &Page{
+		Key:             article.Key,
+		Title:           article.Title,
+		Tags:            revision.SortedTags,
+		HighlightedTags: append([]string{}, article.Tags...),
+		History:         history,
+		FooterText:      fmt.Sprintf("There are %d revisions listed.", len(history.Revisions)),
+		contentTemplate: htmlTemplate("history"),
+		revisionHash:    revision.Hash,
+	}
+}
+
+func (s *WebServer) renderChanges(w io.Writer, revision *Revision) error {
+	return s.renderPage(w, &Page{
+		Key:          "changes",
+		Title:        "Recent Changes",
+		Tags:         revision.SortedTags,
+		Changes:      revision.Changes,
+		FooterText:   fmt.Sprintf("There are %d changes listed.", len(revision.Changes)),
+		FeedPath:     "/" + ChangesFeedPath,
+		revisionHash: revision.Hash,
+	})
+}
+
+func (s *WebServer) renderSearchResults(w io.Writer, query string, results []SearchResult, revision *Revision) error {
+	footerText := "You can use the sidebar to explore the website."
+	if query != "" {
+		footerText = fmt.Sprintf("There are %d articles found.", len(results))
+	}
+
+	return s.renderPage(w, &Page{
+		Key:           "search",
+		Title:         "Search Results",
+		Tags:          revision.SortedTags,
+		SearchQuery:   query,
+		SearchResults: results,
+		FooterText:    footerText,
+		revisionHash:  revision.Hash,
 	})
 }
 
 func (s *WebServer) renderPage(w io.Writer, page *Page) error {
-	t, err := template.ParseFiles(s.env.TemplatePath(PageTemplate))
-	if err == nil {
-		if page.contentTemplate == "" {
-			page.contentTemplate = htmlTemplate(page.Key)
-			if page.isCompiledContent {
-				panic(s.error("impossible server state: WebServer.renderPage: static page marked as isCompiledContent"))
-			}
-		}
-		contentTemplatePath := s.env.TemplatePath(page.contentTemplate)
+	if page.contentTemplate == "" {
+		page.contentTemplate = htmlTemplate(page.Key)
 		if page.isCompiledContent {
-			contentTemplatePath = s.env.CompiledTemplatePath(page.contentTemplate)
+			panic(s.error("impossible server state: WebServer.renderPage: static page marked as isCompiledContent"))
 		}
-		t.New(ContentPlaceholderTemplate).Parse(fmt.Sprintf(ContentPlaceholderTemplateFmt, page.contentTemplate))
-		_, err = t.ParseFiles(contentTemplatePath)
 	}
+
+	t, err := s.pageTemplate(page)
 	if err != nil {
 		return fmt.Errorf("failed to parse one or more template files: %v", err)
 	}
 	return t.ExecuteTemplate(w, PageTemplate, page)
 }
 
+// pageTemplate returns the parsed template for the page, from the template cache unless
+// templates are configured to be reloaded on every request.
+func (s *WebServer) pageTemplate(page *Page) (*template.Template, error) {
+	pageTemplatePath := s.env.TemplatePath(PageTemplate)
+	contentTemplatePath := s.env.TemplatePath(page.contentTemplate)
+	if page.isCompiledContent {
+		contentTemplatePath = s.env.CompiledTemplatePath(page.contentTemplate)
+	}
+
+	parse := func() (*template.Template, error) {
+		t, err := template.ParseFiles(pageTemplatePath)
+		if err != nil {
+			return nil, err
+		}
+		t.New(ContentPlaceholderTemplate).Parse(fmt.Sprintf(ContentPlaceholderTemplateFmt, page.contentTemplate))
+		return t.ParseFiles(contentTemplatePath)
+	}
+
+	if s.settings.ReloadTemplates {
+		return parse()
+	}
+
+	return s.templates.Get(
+		fmt.Sprintf("%s@%s", page.contentTemplate, page.revisionHash),
+		[]string{pageTemplatePath, contentTemplatePath},
+		parse,
+	)
+}
+
 func htmlTemplate(key string) string {
 	return fmt.Sprintf("%s.html", key)
 }
 
-func compiledHTMLTemplate(key string, versionHash string) string {
-	return fmt.Sprintf("%s_%s.html", key, versionHash)
+func compiledHTMLTemplate(contentHash string) string {
+	return fmt.Sprintf("%s.html", contentHash)
 }
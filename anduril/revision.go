@@ -1,5 +1,12 @@
 package anduril
 
+import (
+	"sync"
+	"time"
+
+	"github.com/cicovic-andrija/anduril/repository"
+)
+
 // ObjectType represents a type of object within a revision.
 type ObjectType int
 
@@ -10,8 +17,8 @@ const (
 )
 
 // Revision is a version (identified by Hash) of a set of objects
-// that represent a set of data files (articles) stored on
-// the file system location written in ContainerPath.
+// that represent a set of data files (articles) merged from
+// the revisions of all sources listed in Sources.
 type Revision struct {
 	Articles      map[string]*Article
 	GroupsByDate  []ArticleGroup
@@ -20,8 +27,45 @@ type Revision struct {
 	Tags          map[string][]*Article
 	SortedTags    []string
 	DefaultTag    string
-	ContainerPath string
+	Index         *SearchIndex
+	Feeds         map[string][]byte
+	Changes       []ArticleChange
+	Pages         *PageCache
+	LastModified  time.Time
+	Sources       []SourceRevision
 	Hash          string
+	BuiltAt       time.Time
+	// Histories of articles, keyed by article key, loaded from repositories on demand.
+	histories     map[string][]repository.FileRevision
+	historiesLock *sync.Mutex
+	// Historical revisions are built on demand from the history of a repository,
+	// and are neither searched nor syndicated.
+	historical bool
+}
+
+// NewRevision returns an empty revision, ready to be processed.
+func NewRevision() *Revision {
+	return &Revision{
+		Articles: make(map[string]*Article),
+		Tags:     make(map[string][]*Article),
+		Index:    NewSearchIndex(),
+		Pages:    NewPageCache(),
+		BuiltAt:  time.Now().UTC(),
+
+		histories:     make(map[string][]repository.FileRevision),
+		historiesLock: &sync.Mutex{},
+	}
+}
+
+// addArticle caches the article by key and by tags, and indexes its text for full-text search.
+func (r *Revision) addArticle(article *Article, text string) {
+	r.Articles[article.Key] = article
+	for _, tag := range article.Tags {
+		r.Tags[tag] = append(r.Tags[tag], article)
+	}
+	if !r.historical {
+		r.Index.add(article, text)
+	}
 }
 
 type ArticleGroup struct {
@@ -49,6 +93,13 @@ func (r *Revision) GetArticle(key string) *Article {
 	return nil
 }
 
+func (r *Revision) GetFeed(path string) []byte {
+	if feed, exists := r.Feeds[path]; exists {
+		return feed
+	}
+	return nil
+}
+
 func (r *Revision) SearchByTag(key string) []*Article {
 	if articles, exists := r.Tags[key]; exists {
 		return articles
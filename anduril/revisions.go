@@ -0,0 +1,125 @@
+package anduril
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cicovic-andrija/anduril/service"
+)
+
+// History of revisions. The last settings.retained_revisions revisions are retained
+// in memory, with their compiled files, and the served revision can be pinned to any
+// of them. The pin is persisted in the work directory, and survives restarts.
+
+var ErrRevisionNotRetained = errors.New("revision not retained")
+
+// publishRevision adds the revision to the history of retained revisions, and serves it
+// unless the served revision is pinned. Revisions dropped from the history are no longer
+// referenced, and their compiled files are removed by the next cleanup.
+// Must be called with buildLock held.
+func (s *WebServer) publishRevision(revision *Revision, trace service.TraceCallback) {
+	s.revisionLock.Lock()
+	defer s.revisionLock.Unlock()
+
+	// Revisions not found before might be found in the history of synced repositories.
+	s.history.ClearMisses()
+
+	// A revision which is built again (e.g. after a revert of a source) replaces the old one.
+	revisions := []*Revision{revision}
+	for _, retained := range s.revisions {
+		if retained.Hash != revision.Hash {
+			revisions = append(revisions, retained)
+		}
+	}
+
+	// The pinned revision is retained even when it is older than all other retained revisions.
+	s.revisions = revisions[:0]
+	for i, retained := range revisions {
+		if i < s.settings.RetainedRevisions || retained.Hash == s.pinnedHash {
+			s.revisions = append(s.revisions, retained)
+		} else {
+			trace("revision %s dropped from the history", retained.Hash)
+		}
+	}
+
+	if s.pinnedHash != "" {
+		if pinned := s.retainedRevision(s.pinnedHash); pinned != nil {
+			if s.latestRevision != pinned {
+				s.latestRevision = pinned
+				s.templates.Invalidate()
+			}
+			trace("served revision is pinned to %s, revision %s retained but not served", s.pinnedHash, revision.Hash)
+			return
+		}
+		s.warn("pinned revision %s is not retained, serving revision %s instead", s.pinnedHash, revision.Hash)
+	}
+
+	s.latestRevision = revision
+	s.templates.Invalidate()
+	trace("latest revision updated to %s (%v)", revision.Hash, revision.Sources)
+}
+
+// retainedRevision returns the retained revision identified by hash, or nil if it is not retained.
+// Must be called with revisionLock held.
+func (s *WebServer) retainedRevision(hash string) *Revision {
+	for _, revision := range s.revisions {
+		if revision.Hash == hash {
+			return revision
+		}
+	}
+	return nil
+}
+
+// pinRevision serves the retained revision identified by hash until the pin is removed,
+// regardless of new revisions, and persists the pin.
+func (s *WebServer) pinRevision(hash string) error {
+	s.revisionLock.Lock()
+	defer s.revisionLock.Unlock()
+
+	pinned := s.retainedRevision(hash)
+	if pinned == nil {
+		return ErrRevisionNotRetained
+	}
+
+	if err := os.WriteFile(s.env.RevisionPinPath(), []byte(hash+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to persist revision pin: %v", err)
+	}
+
+	s.pinnedHash = hash
+	s.latestRevision = pinned
+	s.templates.Invalidate()
+	return nil
+}
+
+// unpinRevision removes the pin, and serves the latest retained revision again.
+func (s *WebServer) unpinRevision() error {
+	s.revisionLock.Lock()
+	defer s.revisionLock.Unlock()
+
+	if err := os.Remove(s.env.RevisionPinPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove persisted revision pin: %v", err)
+	}
+
+	s.pinnedHash = ""
+	if len(s.revisions) > 0 {
+		s.latestRevision = s.revisions[0]
+		s.templates.Invalidate()
+	}
+	return nil
+}
+
+// loadRevisionPin loads the pin persisted by a previous run of the server, if any.
+// The pinned revision is served as soon as it is retained again.
+func (s *WebServer) loadRevisionPin() error {
+	content, err := os.ReadFile(s.env.RevisionPinPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read persisted revision pin: %v", err)
+	}
+	s.pinnedHash = strings.TrimSpace(string(content))
+	return nil
+}
@@ -0,0 +1,91 @@
+package anduril_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+)
+
+func newTestRevision(hash string) *anduril.Revision {
+	revision := anduril.NewRevision()
+	revision.Hash = hash
+	return revision
+}
+
+func expectRevisions(t *testing.T, server *anduril.WebServer, expected []testRevisionInfo) {
+	t.Helper()
+
+	if found := retainedRevisions(t, server); !reflect.DeepEqual(found, expected) {
+		t.Fatalf("retained revisions:\nexpected: %+v\nfound:    %+v", expected, found)
+	}
+}
+
+func TestRetainedRevisions(t *testing.T) {
+	server := newAdminTestServer(t, t.TempDir(), 2)
+
+	for _, hash := range []string{"a", "b", "c"} {
+		server.PublishRevision(newTestRevision(hash))
+	}
+	expectRevisions(t, server, []testRevisionInfo{
+		{Hash: "c", Served: true},
+		{Hash: "b"},
+	})
+
+	// A revision built again replaces the retained one.
+	server.PublishRevision(newTestRevision("b"))
+	expectRevisions(t, server, []testRevisionInfo{
+		{Hash: "b", Served: true},
+		{Hash: "c"},
+	})
+}
+
+func TestPinnedRevisionRetained(t *testing.T) {
+	server := newAdminTestServer(t, t.TempDir(), 2)
+
+	server.PublishRevision(newTestRevision("a"))
+	server.PublishRevision(newTestRevision("b"))
+	pinRevision(t, server, "a")
+
+	// The pinned revision is served, and retained beyond the retention window.
+	server.PublishRevision(newTestRevision("c"))
+	server.PublishRevision(newTestRevision("d"))
+	expectRevisions(t, server, []testRevisionInfo{
+		{Hash: "d"},
+		{Hash: "c"},
+		{Hash: "a", Served: true, Pinned: true},
+	})
+
+	response := serveAdmin(server, server.AdminUnpinRevisionHandler, http.MethodPost, testAdminToken, nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("unpin: expected: %d found: %d", http.StatusOK, response.Code)
+	}
+	server.PublishRevision(newTestRevision("e"))
+	expectRevisions(t, server, []testRevisionInfo{
+		{Hash: "e", Served: true},
+		{Hash: "d"},
+	})
+}
+
+func TestRevisionPinPersisted(t *testing.T) {
+	wd := t.TempDir()
+	server := newAdminTestServer(t, wd, 2)
+	server.PublishRevision(newTestRevision("a"))
+	server.PublishRevision(newTestRevision("b"))
+	pinRevision(t, server, "a")
+
+	// After a restart, the pinned revision is served as soon as it is retained again.
+	server = newAdminTestServer(t, wd, 2)
+	server.PublishRevision(newTestRevision("b"))
+	expectRevisions(t, server, []testRevisionInfo{
+		{Hash: "b", Served: true},
+	})
+
+	server.PublishRevision(newTestRevision("a"))
+	server.PublishRevision(newTestRevision("c"))
+	expectRevisions(t, server, []testRevisionInfo{
+		{Hash: "c"},
+		{Hash: "a", Served: true, Pinned: true},
+	})
+}
@@ -0,0 +1,371 @@
+package anduril
+
+import (
+	"html/template"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Structures and routines for full-text search over articles of a revision.
+
+// Name of the URL query parameter which holds the search query.
+const SearchQueryParam = "what"
+
+// Maximum number of results returned for a single search query.
+const SearchResultLimit = 50
+
+// Relative importance of a term based on the article field it was found in.
+const (
+	titleTermWeight = 8.0
+	tagTermWeight   = 5.0
+	typeTermWeight  = 3.0
+	bodyTermWeight  = 1.0
+)
+
+// Approximate number of characters shown on each side of the first match in a snippet.
+const snippetRadius = 120
+
+var (
+	markdownImageRegexp  = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownLinkRegexp   = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	htmlTagRegexp        = regexp.MustCompile(`<[^>]+>`)
+	markdownSyntaxRegexp = regexp.MustCompile("[#*_`>|~]+")
+	whitespaceRegexp     = regexp.MustCompile(`\s+`)
+)
+
+// SearchIndex is an inverted index over titles, tags, types and bodies of articles.
+// The index is built once per revision and is read-only afterwards.
+type SearchIndex struct {
+	postings  map[string][]posting
+	documents map[string]*searchDocument
+	tags      map[string]bool
+}
+
+type posting struct {
+	key    string
+	weight float64
+}
+
+type searchDocument struct {
+	article    *Article
+	titleTerms []string
+	text       string
+}
+
+type SearchResult struct {
+	Article *Article
+	Title   template.HTML
+	Snippet template.HTML
+	score   float64
+}
+
+// Kinds of search suggestions.
+const (
+	ArticleSuggestion = "article"
+	TagSuggestion     = "tag"
+)
+
+// Suggestion is a search-as-you-type result pointing directly to an article or a tag.
+type Suggestion struct {
+	Kind  string `json:"kind"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		postings:  make(map[string][]posting),
+		documents: make(map[string]*searchDocument),
+		tags:      make(map[string]bool),
+	}
+}
+
+// Add indexes the article and its body in markdown format.
+func (idx *SearchIndex) Add(article *Article, body []byte) {
+	idx.add(article, plainText(body))
+}
+
+// add indexes the article and the plain text of its body.
+func (idx *SearchIndex) add(article *Article, text string) {
+	doc := &searchDocument{
+		article:    article,
+		titleTerms: tokenize(article.Title),
+		text:       text,
+	}
+	idx.documents[article.Key] = doc
+
+	weights := make(map[string]float64)
+	for _, term := range doc.titleTerms {
+		weights[term] += titleTermWeight
+	}
+	for _, tag := range article.Tags {
+		idx.tags[tag] = true
+		for _, term := range tokenize(tag) {
+			weights[term] += tagTermWeight
+		}
+	}
+	for _, term := range tokenize(article.Type) {
+		weights[term] += typeTermWeight
+	}
+	for _, term := range tokenize(doc.text) {
+		weights[term] += bodyTermWeight
+	}
+
+	for term, weight := range weights {
+		idx.postings[term] = append(idx.postings[term], posting{key: article.Key, weight: weight})
+	}
+}
+
+// text returns the indexed plain text of the article's body.
+func (idx *SearchIndex) text(key string) string {
+	if doc, found := idx.documents[key]; found {
+		return doc.text
+	}
+	return ""
+}
+
+// Search returns articles which contain all terms of the query, ordered by relevance.
+func (idx *SearchIndex) Search(query string) []SearchResult {
+	terms := uniqueTerms(tokenize(query))
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var (
+		scores  = make(map[string]float64)
+		matched = make(map[string]int)
+		total   = float64(len(idx.documents))
+	)
+	for _, term := range terms {
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			// Every term must match.
+			return nil
+		}
+		idf := math.Log(1 + total/float64(len(postings)))
+		for _, p := range postings {
+			scores[p.key] += (1 + math.Log(p.weight)) * idf
+			matched[p.key]++
+		}
+	}
+
+	termSet := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		termSet[term] = true
+	}
+
+	results := []SearchResult{}
+	for key, score := range scores {
+		if matched[key] != len(terms) {
+			continue
+		}
+		doc := idx.documents[key]
+		results = append(results, SearchResult{
+			Article: doc.article,
+			Title:   highlight(doc.article.Title, termSet),
+			Snippet: snippet(doc.text, termSet),
+			score:   score,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].Article.Title < results[j].Article.Title
+	})
+
+	if len(results) > SearchResultLimit {
+		results = results[:SearchResultLimit]
+	}
+	return results
+}
+
+// Suggest returns at most limit articles whose titles contain words starting with
+// every term of the query, followed by tags which start with the query.
+func (idx *SearchIndex) Suggest(query string, limit int) []Suggestion {
+	terms := uniqueTerms(tokenize(query))
+	if len(terms) == 0 || limit <= 0 {
+		return []Suggestion{}
+	}
+
+	articles := []*Article{}
+	for _, doc := range idx.documents {
+		if matchesPrefixes(doc.titleTerms, terms) {
+			articles = append(articles, doc.article)
+		}
+	}
+	sort.Slice(articles, func(i, j int) bool {
+		return articles[i].Title < articles[j].Title
+	})
+
+	tags := []string{}
+	prefix := strings.ToLower(strings.TrimSpace(query))
+	for tag := range idx.tags {
+		if strings.HasPrefix(strings.ToLower(tag), prefix) {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+
+	suggestions := []Suggestion{}
+	for _, article := range articles {
+		if len(suggestions) == limit {
+			return suggestions
+		}
+		suggestions = append(suggestions, Suggestion{
+			Kind:  ArticleSuggestion,
+			Title: article.Title,
+			URL:   "/articles/" + article.Key,
+		})
+	}
+	for _, tag := range tags {
+		if len(suggestions) == limit {
+			return suggestions
+		}
+		suggestions = append(suggestions, Suggestion{
+			Kind:  TagSuggestion,
+			Title: tag,
+			URL:   "/tags/" + tag,
+		})
+	}
+	return suggestions
+}
+
+// matchesPrefixes reports whether every prefix is a prefix of at least one of the terms.
+func matchesPrefixes(terms []string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		found := false
+		for _, term := range terms {
+			if strings.HasPrefix(term, prefix) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func isTermRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isTermRune(r)
+	})
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	unique := terms[:0]
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
+
+// plainText strips the most common markdown and HTML syntax from the body,
+// leaving text suitable for indexing and snippets.
+func plainText(body []byte) string {
+	text := string(body)
+	text = markdownImageRegexp.ReplaceAllString(text, "$1")
+	text = markdownLinkRegexp.ReplaceAllString(text, "$1")
+	text = htmlTagRegexp.ReplaceAllString(text, " ")
+	text = markdownSyntaxRegexp.ReplaceAllString(text, " ")
+	text = whitespaceRegexp.ReplaceAllString(text, " ")
+	return strings.TrimSpace(text)
+}
+
+// snippet returns an excerpt of text around the first occurrence of any of the terms,
+// with all occurrences of the terms highlighted.
+func snippet(text string, terms map[string]bool) template.HTML {
+	first := -1
+	forEachToken(text, func(start, end int) {
+		if first < 0 && terms[strings.ToLower(text[start:end])] {
+			first = start
+		}
+	})
+	if first < 0 {
+		// Only title, tags or type matched; show the beginning of the text.
+		first = 0
+	}
+
+	start := first - snippetRadius
+	if start <= 0 {
+		start = 0
+	} else if space := strings.IndexByte(text[start:first], ' '); space >= 0 {
+		start += space + 1
+	}
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start++
+	}
+
+	end := first + 2*snippetRadius
+	if end >= len(text) {
+		end = len(text)
+	} else if space := strings.LastIndexByte(text[first:end], ' '); space > 0 {
+		end = first + space
+	}
+	// Never split a multi-byte character.
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+
+	excerpt := highlight(text[start:end], terms)
+	if start > 0 {
+		excerpt = "&hellip; " + excerpt
+	}
+	if end < len(text) {
+		excerpt += " &hellip;"
+	}
+	return excerpt
+}
+
+// highlight escapes text and wraps all occurrences of the terms in <mark> elements.
+func highlight(text string, terms map[string]bool) template.HTML {
+	var (
+		b    strings.Builder
+		last = 0
+	)
+	forEachToken(text, func(start, end int) {
+		if !terms[strings.ToLower(text[start:end])] {
+			return
+		}
+		b.WriteString(template.HTMLEscapeString(text[last:start]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[start:end]))
+		b.WriteString("</mark>")
+		last = end
+	})
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+	return template.HTML(b.String())
+}
+
+// forEachToken calls fn with byte offsets of every token in text.
+func forEachToken(text string, fn func(start, end int)) {
+	start := -1
+	for i, r := range text {
+		if isTermRune(r) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			fn(start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		fn(start, len(text))
+	}
+}
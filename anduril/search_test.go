@@ -0,0 +1,82 @@
+package anduril_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+)
+
+func newTestIndex() *anduril.SearchIndex {
+	index := anduril.NewSearchIndex()
+	index.Add(
+		&anduril.Article{Key: "dry-suit", Title: "SSI Dry Suit", Type: "note", Tags: []string{"diving", "ssi"}},
+		[]byte("# Dry Suit\n\nA dry suit keeps the diver *warm* in cold water."),
+	)
+	index.Add(
+		&anduril.Article{Key: "buoyancy", Title: "SSI Peak Performance Buoyancy", Type: "note", Tags: []string{"diving"}},
+		[]byte("Buoyancy control with a [dry suit](https://example.com) requires practice."),
+	)
+	index.Add(
+		&anduril.Article{Key: "go-notes", Title: "Go Notes", Type: "reference", Tags: []string{"programming"}},
+		[]byte("Notes on <b>Go</b> generics."),
+	)
+	return index
+}
+
+func TestSearchRanksTitleMatchesFirst(t *testing.T) {
+	results := newTestIndex().Search("dry suit")
+	if len(results) != 2 {
+		t.Fatalf("results: expected len: %d found: %d", 2, len(results))
+	}
+	if results[0].Article.Key != "dry-suit" {
+		t.Fatalf("results[0]: expected: %q found: %q", "dry-suit", results[0].Article.Key)
+	}
+	if results[1].Article.Key != "buoyancy" {
+		t.Fatalf("results[1]: expected: %q found: %q", "buoyancy", results[1].Article.Key)
+	}
+}
+
+func TestSearchRequiresAllTerms(t *testing.T) {
+	if results := newTestIndex().Search("diving generics"); len(results) != 0 {
+		t.Fatalf("results: expected len: %d found: %d", 0, len(results))
+	}
+}
+
+func TestSearchHighlightsTerms(t *testing.T) {
+	results := newTestIndex().Search("GO")
+	if len(results) != 1 {
+		t.Fatalf("results: expected len: %d found: %d", 1, len(results))
+	}
+	if title := string(results[0].Title); title != "<mark>Go</mark> Notes" {
+		t.Fatalf("title: expected: %q found: %q", "<mark>Go</mark> Notes", title)
+	}
+	if snippet := string(results[0].Snippet); !strings.Contains(snippet, "on <mark>Go</mark> generics") {
+		t.Fatalf("snippet: unexpected: %q", snippet)
+	}
+}
+
+func TestSearchEmptyQuery(t *testing.T) {
+	if results := newTestIndex().Search("  ?! "); results != nil {
+		t.Fatalf("results: expected: nil found: %v", results)
+	}
+}
+
+func TestSuggestMatchesTitlePrefixesAndTags(t *testing.T) {
+	suggestions := newTestIndex().Suggest("Div", 5)
+	if len(suggestions) != 1 {
+		t.Fatalf("suggestions: expected len: %d found: %d", 1, len(suggestions))
+	}
+	if suggestions[0].Kind != anduril.TagSuggestion || suggestions[0].URL != "/tags/diving" {
+		t.Fatalf("suggestions[0]: unexpected: %+v", suggestions[0])
+	}
+
+	suggestions = newTestIndex().Suggest("ssi bu", 5)
+	if len(suggestions) != 1 || suggestions[0].URL != "/articles/buoyancy" {
+		t.Fatalf("suggestions: unexpected: %+v", suggestions)
+	}
+
+	if suggestions = newTestIndex().Suggest("s", 1); len(suggestions) != 1 {
+		t.Fatalf("suggestions: expected len: %d found: %d", 1, len(suggestions))
+	}
+}
@@ -0,0 +1,120 @@
+package anduril
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Structures and routines for generating sitemap.xml and robots.txt of a revision.
+
+const (
+	SitemapFile = "sitemap.xml"
+	RobotsFile  = "robots.txt"
+)
+
+// Namespace of the sitemap protocol XML schema.
+const SitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Location     string `xml:"loc"`
+	LastModified string `xml:"lastmod,omitempty"`
+}
+
+func (s *WebServer) renderSitemap(revision *Revision) ([]byte, error) {
+	urlSet := sitemapURLSet{
+		XMLNS: SitemapNamespace,
+	}
+	add := func(path string, lastModified time.Time) {
+		if s.isDisallowedForRobots(path) {
+			return
+		}
+		u := sitemapURL{Location: s.absoluteURL(path)}
+		if !lastModified.IsZero() {
+			u.LastModified = lastModified.Format(time.RFC3339)
+		}
+		urlSet.URLs = append(urlSet.URLs, u)
+	}
+
+	staticKeys := make([]string, 0, len(StaticPages))
+	for key := range StaticPages {
+		// Error pages are not meant to be indexed.
+		if key != "404" {
+			staticKeys = append(staticKeys, key)
+		}
+	}
+	sort.Strings(staticKeys)
+	for _, key := range staticKeys {
+		add("/"+key, time.Time{})
+	}
+
+	var siteModified time.Time
+	articleKeys := make([]string, 0, len(revision.Articles))
+	for key, article := range revision.Articles {
+		if isPublicArticle(article) {
+			articleKeys = append(articleKeys, key)
+			siteModified = latest(siteModified, lastModified(article))
+		}
+	}
+	sort.Strings(articleKeys)
+
+	add("/articles", siteModified)
+	add("/tags", siteModified)
+	for _, key := range articleKeys {
+		add("/articles/"+url.PathEscape(key), lastModified(revision.Articles[key]))
+	}
+
+	// Tags are listed only if they have public articles, which leaves out the private tag.
+	for _, tag := range revision.SortedTags {
+		var (
+			public      bool
+			tagModified time.Time
+		)
+		for _, article := range revision.Tags[tag] {
+			if isPublicArticle(article) {
+				public = true
+				tagModified = latest(tagModified, lastModified(article))
+			}
+		}
+		if public {
+			add("/tags/"+url.PathEscape(tag), tagModified)
+		}
+	}
+
+	body, err := xml.MarshalIndent(urlSet, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+func (s *WebServer) renderRobots() []byte {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	if len(s.settings.RobotsDisallow) == 0 {
+		b.WriteString("Disallow:\n")
+	}
+	for _, path := range s.settings.RobotsDisallow {
+		fmt.Fprintf(&b, "Disallow: %s\n", path)
+	}
+	fmt.Fprintf(&b, "\nSitemap: %s\n", s.absoluteURL("/"+SitemapFile))
+	return []byte(b.String())
+}
+
+func (s *WebServer) isDisallowedForRobots(path string) bool {
+	for _, disallowed := range s.settings.RobotsDisallow {
+		if strings.HasPrefix(path, disallowed) {
+			return true
+		}
+	}
+	return false
+}
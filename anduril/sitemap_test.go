@@ -0,0 +1,96 @@
+package anduril_test
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+)
+
+type testSitemapURL struct {
+	Location     string `xml:"loc"`
+	LastModified string `xml:"lastmod"`
+}
+
+func getSitemap(t *testing.T, server *anduril.WebServer) []testSitemapURL {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	server.SitemapHandlerLocked(response, httptest.NewRequest(http.MethodGet, "/"+anduril.SitemapFile, nil))
+	if response.Code != http.StatusOK {
+		t.Fatalf("sitemap: expected: %d found: %d", http.StatusOK, response.Code)
+	}
+	sitemap := struct {
+		XMLName xml.Name         `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+		URLs    []testSitemapURL `xml:"url"`
+	}{}
+	if err := xml.Unmarshal(response.Body.Bytes(), &sitemap); err != nil {
+		t.Fatalf("sitemap: %v", err)
+	}
+	return sitemap.URLs
+}
+
+func getRobots(t *testing.T, server *anduril.WebServer) string {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	server.RobotsHandlerLocked(response, httptest.NewRequest(http.MethodGet, "/"+anduril.RobotsFile, nil))
+	if response.Code != http.StatusOK {
+		t.Fatalf("robots.txt: expected: %d found: %d", http.StatusOK, response.Code)
+	}
+	return response.Body.String()
+}
+
+func TestSitemap(t *testing.T) {
+	server := newFeedTestServer(t, nil)
+
+	// Private articles, tags of only private articles, and the error page are not listed,
+	// and private articles don't count towards the last modification of pages.
+	expected := []testSitemapURL{
+		{feedSiteURL + "/about", ""},
+		{feedSiteURL + "/home", ""},
+		{feedSiteURL + "/look-and-feel", ""},
+		{feedSiteURL + "/articles", "2023-06-01T10:00:00Z"},
+		{feedSiteURL + "/tags", "2023-06-01T10:00:00Z"},
+		{feedSiteURL + "/articles/new", "2023-03-01T10:00:00Z"},
+		{feedSiteURL + "/articles/old", "2023-01-01T10:00:00Z"},
+		{feedSiteURL + "/articles/updated", "2023-06-01T10:00:00Z"},
+		{feedSiteURL + "/tags/go", "2023-06-01T10:00:00Z"},
+		{feedSiteURL + "/tags/misc", "2023-06-01T10:00:00Z"},
+	}
+	if found := getSitemap(t, server); !reflect.DeepEqual(found, expected) {
+		t.Fatalf("sitemap: expected: %v found: %v", expected, found)
+	}
+
+	expectedRobots := "User-agent: *\nDisallow:\n\nSitemap: " + feedSiteURL + "/sitemap.xml\n"
+	if found := getRobots(t, server); found != expectedRobots {
+		t.Fatalf("robots.txt: expected: %q found: %q", expectedRobots, found)
+	}
+}
+
+func TestSitemapRobotsDisallow(t *testing.T) {
+	server := newFeedTestServer(t, func(config *anduril.Config) {
+		config.Settings.RobotsDisallow = []string{"/tags/", "/articles/old", "/look-and-feel"}
+	})
+
+	// Pages disallowed for robots are not listed.
+	expected := []testSitemapURL{
+		{feedSiteURL + "/about", ""},
+		{feedSiteURL + "/home", ""},
+		{feedSiteURL + "/articles", "2023-06-01T10:00:00Z"},
+		{feedSiteURL + "/tags", "2023-06-01T10:00:00Z"},
+		{feedSiteURL + "/articles/new", "2023-03-01T10:00:00Z"},
+		{feedSiteURL + "/articles/updated", "2023-06-01T10:00:00Z"},
+	}
+	if found := getSitemap(t, server); !reflect.DeepEqual(found, expected) {
+		t.Fatalf("sitemap: expected: %v found: %v", expected, found)
+	}
+
+	expectedRobots := "User-agent: *\nDisallow: /tags/\nDisallow: /articles/old\nDisallow: /look-and-feel\n\nSitemap: " + feedSiteURL + "/sitemap.xml\n"
+	if found := getRobots(t, server); found != expectedRobots {
+		t.Fatalf("robots.txt: expected: %q found: %q", expectedRobots, found)
+	}
+}
@@ -0,0 +1,174 @@
+package anduril
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/cicovic-andrija/anduril/repository"
+	"github.com/cicovic-andrija/libgo/fs"
+)
+
+// Content sources. Every source is a named repository synced by its own periodic task,
+// and articles of all sources are merged into a single revision.
+
+// Source configures a named content repository.
+type Source struct {
+	// Name identifies the source in logs and names its working directory.
+	Name string `json:"name"`
+
+	// Period of repository sync; if empty, settings.repository_sync_period is used.
+	SyncPeriod    string        `json:"sync_period"`
+	SyncPeriodDur time.Duration `json:"-"`
+
+	// Prefix prepended to keys of all articles of the source (e.g. "runbooks-").
+	KeyPrefix string `json:"key_prefix"`
+
+	// Tag added to all articles of the source.
+	DefaultTag string `json:"default_tag"`
+
+	Repository repository.Config `json:"repository"`
+}
+
+var (
+	sourceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	keyPrefixPattern  = regexp.MustCompile(`^[a-z0-9_-]*$`)
+)
+
+// Validate validates the source configuration and sets default values of optional fields.
+func (s *Source) Validate(defaultSyncPeriod time.Duration) error {
+	if !sourceNamePattern.MatchString(s.Name) {
+		return fmt.Errorf("name: must consist of lowercase letters, digits, '-' and '_': %q", s.Name)
+	}
+
+	if s.SyncPeriod == "" {
+		s.SyncPeriodDur = defaultSyncPeriod
+	} else {
+		dur, err := time.ParseDuration(s.SyncPeriod)
+		if err != nil {
+			return fmt.Errorf("sync period: %v", err)
+		}
+		s.SyncPeriodDur = dur
+	}
+
+	if !keyPrefixPattern.MatchString(s.KeyPrefix) {
+		return fmt.Errorf("key prefix: must consist of lowercase letters, digits, '-' and '_': %q", s.KeyPrefix)
+	}
+
+	return nil
+}
+
+// contentSource is a source and its repository.
+type contentSource struct {
+	Source
+	repository repository.Repository
+	trigger    chan struct{}
+	// The repository is modified by syncs, which hold the write lock, and is read
+	// by builds and readers of its history, which hold the read lock.
+	lock *sync.RWMutex
+	// Whether the repository was synced at least once, successfully or not.
+	// Accessed with buildLock held.
+	attempted bool
+}
+
+func newContentSources(sources []Source, defaultSyncPeriod time.Duration) ([]*contentSource, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("no repositories configured")
+	}
+
+	contentSources := make([]*contentSource, 0, len(sources))
+	names := make(map[string]bool)
+	for _, source := range sources {
+		if err := source.Validate(defaultSyncPeriod); err != nil {
+			return nil, fmt.Errorf("invalid repository %q: %v", source.Name, err)
+		}
+		if names[source.Name] {
+			return nil, fmt.Errorf("invalid repository %q: name is not unique", source.Name)
+		}
+		names[source.Name] = true
+
+		repo, err := repository.New(source.Repository)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repository %q: %v", source.Name, err)
+		}
+
+		contentSources = append(contentSources, &contentSource{
+			Source:     source,
+			repository: repo,
+			trigger:    make(chan struct{}, 1),
+			lock:       &sync.RWMutex{},
+		})
+	}
+
+	return contentSources, nil
+}
+
+// triggerSync wakes up the sync task of the source. Triggers received while a sync
+// is already pending are coalesced into that sync.
+func (c *contentSource) triggerSync() {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// traceTag returns the tag used to trace the sync task of the source.
+func (c *contentSource) traceTag() TraceTag {
+	return TraceTag(string(RepositoryTag) + ":" + c.Name)
+}
+
+// source returns the source with the name, or nil if there is no such source.
+func (s *WebServer) source(name string) *contentSource {
+	for _, source := range s.sources {
+		if source.Name == name {
+			return source
+		}
+	}
+	return nil
+}
+
+// migrateLegacyWorkingDirectory moves the working directory of the single repository of older
+// versions to the working directory of the legacy source, so that it is not cloned again.
+// If the configuration is not legacy, the directory is left in place.
+func (s *WebServer) migrateLegacyWorkingDirectory(legacy bool) {
+	legacyDir := s.env.LegacyRepositoryWorkingDirectory()
+	if exists, _ := fs.DirectoryExists(legacyDir); !exists {
+		return
+	}
+
+	if !legacy {
+		s.warn("legacy working directory %s is no longer used and can be removed", legacyDir)
+		return
+	}
+
+	sourceDir := s.env.SourceWorkingDirectory(LegacySourceName)
+	if exists, _ := fs.DirectoryExists(sourceDir); exists {
+		s.warn("legacy working directory %s not moved: %s already exists", legacyDir, sourceDir)
+		return
+	}
+	if err := os.Rename(legacyDir, sourceDir); err != nil {
+		s.warn("failed to move legacy working directory %s to %s: %v", legacyDir, sourceDir, err)
+		return
+	}
+	s.log("legacy working directory %s moved to %s", legacyDir, sourceDir)
+}
+
+// SourceRevision is a revision of a single source merged into a revision.
+type SourceRevision struct {
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+}
+
+// compositeHash returns a hash which identifies the combination of source revisions.
+func compositeHash(sources []SourceRevision) string {
+	hash := sha256.New()
+	for _, source := range sources {
+		fmt.Fprintf(hash, "%s\x00%s\n", source.Name, source.Hash)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:10]
+}
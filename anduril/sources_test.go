@@ -0,0 +1,198 @@
+package anduril_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+)
+
+func TestSourceDefaultSyncPeriod(t *testing.T) {
+	source := &anduril.Source{Name: "notes"}
+	if err := source.Validate(5 * time.Minute); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if source.SyncPeriodDur != 5*time.Minute {
+		t.Fatalf("sync period: expected: %v found: %v", 5*time.Minute, source.SyncPeriodDur)
+	}
+
+	source = &anduril.Source{Name: "runbooks", SyncPeriod: "1h", KeyPrefix: "runbooks-"}
+	if err := source.Validate(5 * time.Minute); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if source.SyncPeriodDur != time.Hour {
+		t.Fatalf("sync period: expected: %v found: %v", time.Hour, source.SyncPeriodDur)
+	}
+}
+
+func TestSourceValidation(t *testing.T) {
+	for _, source := range []anduril.Source{
+		{Name: ""},
+		{Name: "Team Runbooks"},
+		{Name: "runbooks", KeyPrefix: "runbooks/"},
+		{Name: "runbooks", SyncPeriod: "hourly"},
+	} {
+		if err := source.Validate(time.Minute); err == nil {
+			t.Fatalf("validate: expected an error for %+v", source)
+		}
+	}
+}
+
+func TestMigrateLegacyRepository(t *testing.T) {
+	config := &anduril.Config{}
+	if err := json.Unmarshal([]byte(`{"repository": {"protocol": "file", "repo_path": "/srv/notes"}}`), config); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	legacy, err := config.MigrateLegacyRepository()
+	if err != nil || !legacy {
+		t.Fatalf("migrate: expected a legacy configuration, found: %v, %v", legacy, err)
+	}
+	if len(config.Repositories) != 1 || config.Repositories[0].Name != anduril.LegacySourceName || config.Repositories[0].Repository.RepoPath != "/srv/notes" {
+		t.Fatalf("migrate: unexpected repositories: %+v", config.Repositories)
+	}
+
+	config = &anduril.Config{}
+	if err := json.Unmarshal([]byte(`{"repository": {}, "repositories": [{"name": "notes"}]}`), config); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if _, err = config.MigrateLegacyRepository(); err == nil {
+		t.Fatalf("migrate: expected an error for both repository and repositories")
+	}
+}
+
+func TestMigrateLegacyWorkingDirectory(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		wd := t.TempDir()
+		legacyDir := mkdirAll(t, filepath.Join(wd, "work", "repository"))
+		if err := os.WriteFile(filepath.Join(legacyDir, "marker"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		newTestServer(t, wd, func(config *anduril.Config) {
+			if legacy {
+				config.Repository = &config.Repositories[0].Repository
+				config.Repositories = nil
+			}
+		})
+
+		// The clone is moved only for legacy configurations, and never removed.
+		movedMarker := filepath.Join(wd, "work", "sources", anduril.LegacySourceName, "marker")
+		expected, unexpected := filepath.Join(legacyDir, "marker"), movedMarker
+		if legacy {
+			expected, unexpected = unexpected, expected
+		}
+		if _, err := os.Stat(expected); err != nil {
+			t.Fatalf("legacy %v: %v", legacy, err)
+		}
+		if _, err := os.Stat(unexpected); err == nil {
+			t.Fatalf("legacy %v: %s was not expected to exist", legacy, unexpected)
+		}
+	}
+}
+
+// newMergeTestServer returns a server with two directory sources, notes and runbooks;
+// keys of runbooks are prefixed, and runbooks are tagged by default.
+func newMergeTestServer(t *testing.T) (server *anduril.WebServer, notes string, runbooks string) {
+	notes, runbooks = t.TempDir(), t.TempDir()
+	server = newTestServer(t, t.TempDir(), func(config *anduril.Config) {
+		runbooksSource := newDirectorySource("runbooks", runbooks)
+		runbooksSource.KeyPrefix = "runbooks-"
+		runbooksSource.DefaultTag = "runbook"
+		config.Repositories = []anduril.Source{newDirectorySource("notes", notes), runbooksSource}
+	})
+	return
+}
+
+func syncSources(t *testing.T, server *anduril.WebServer, names ...string) *anduril.Revision {
+	t.Helper()
+
+	for _, name := range names {
+		if err := server.SyncSource(name); err != nil {
+			t.Fatalf("sync %s: %v", name, err)
+		}
+	}
+	return server.LatestRevision()
+}
+
+func TestMergeSources(t *testing.T) {
+	server, notes, runbooks := newMergeTestServer(t)
+	writeArticle(t, notes, "Go.md", "Go", "programming")
+	writeArticle(t, runbooks, "deploy.md", "Deploy", "ops")
+
+	// Sources which are not synced yet are left out of the revision.
+	revision := syncSources(t, server, "notes")
+	if len(revision.Sources) != 1 || len(revision.Articles) != 1 {
+		t.Fatalf("notes: unexpected revision: sources: %v articles: %d", revision.Sources, len(revision.Articles))
+	}
+
+	revision = syncSources(t, server, "runbooks")
+	if len(revision.Sources) != 2 || revision.Sources[0].Name != "notes" || revision.Sources[1].Name != "runbooks" {
+		t.Fatalf("unexpected sources: %v", revision.Sources)
+	}
+	for key, source := range map[string]string{"go": "notes", "runbooks-deploy": "runbooks"} {
+		article, found := revision.Articles[key]
+		if !found {
+			t.Fatalf("article %s not found", key)
+		}
+		if article.Source != source {
+			t.Fatalf("article %s: source: expected: %s found: %s", key, source, article.Source)
+		}
+	}
+	if len(revision.Articles) != 2 {
+		t.Fatalf("articles: expected: 2 found: %d", len(revision.Articles))
+	}
+}
+
+func TestMergeSourcesKeyCollision(t *testing.T) {
+	server, notes, runbooks := newMergeTestServer(t)
+	writeArticle(t, notes, "runbooks-deploy.md", "Deploying runbooks", "docs")
+	writeArticle(t, runbooks, "deploy.md", "Deploy", "ops")
+
+	// The article of the source listed first wins, regardless of the order of syncs.
+	revision := syncSources(t, server, "runbooks", "notes")
+	article, found := revision.Articles["runbooks-deploy"]
+	if !found || article.Source != "notes" || article.Title != "Deploying runbooks" {
+		t.Fatalf("expected the article of notes, found: %+v", article)
+	}
+	if len(revision.Articles) != 1 {
+		t.Fatalf("articles: expected: 1 found: %d", len(revision.Articles))
+	}
+}
+
+func TestSourceDefaultTag(t *testing.T) {
+	server, notes, runbooks := newMergeTestServer(t)
+	writeArticle(t, notes, "untagged-note.md", "Untagged note")
+	writeArticle(t, notes, "private-note.md", "Private note", anduril.PrivateArticleTag)
+	writeArticle(t, runbooks, "deploy.md", "Deploy", "ops")
+	writeArticle(t, runbooks, "restart.md", "Restart", "ops", "runbook")
+	writeArticle(t, runbooks, "untagged.md", "Untagged runbook")
+
+	revision := syncSources(t, server, "notes", "runbooks")
+	for key, tags := range map[string][]string{
+		"untagged-note":     {anduril.PrivateArticleTag},
+		"runbooks-deploy":   {"ops", "runbook"},
+		"runbooks-restart":  {"ops", "runbook"},
+		"runbooks-untagged": {anduril.PrivateArticleTag, "runbook"},
+	} {
+		article, found := revision.Articles[key]
+		if !found {
+			t.Fatalf("article %s not found", key)
+		}
+		if !reflect.DeepEqual(article.Tags, tags) {
+			t.Fatalf("article %s: tags: expected: %v found: %v", key, tags, article.Tags)
+		}
+	}
+
+	// Articles tagged as private are not published, unlike untagged articles, which are
+	// only tagged as private after the check; the default tag does not make them public.
+	if _, found := revision.Articles["private-note"]; found {
+		t.Fatalf("private article published")
+	}
+	if len(revision.Articles) != 4 {
+		t.Fatalf("articles: expected: 4 found: %d", len(revision.Articles))
+	}
+}
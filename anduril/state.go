@@ -0,0 +1,140 @@
+package anduril
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Persisted state of retained revisions. Processed metadata of retained revisions is saved
+// to the work directory whenever a revision is published, and restored on start-up, so that
+// the server can serve content before the first sync of the repositories completes. Tags,
+// groups, the search index and feeds are derived from articles, and are rebuilt on restore.
+
+// Version of the format of the persisted state; state of other versions is ignored.
+const revisionStateVersion = 1
+
+type revisionStateFile struct {
+	Version   int             `json:"version"`
+	Revisions []revisionState `json:"revisions"`
+}
+
+type revisionState struct {
+	Hash     string           `json:"hash"`
+	Sources  []SourceRevision `json:"sources"`
+	BuiltAt  time.Time        `json:"built_at"`
+	Articles []articleState   `json:"articles"`
+	Changes  []ArticleChange  `json:"changes"`
+}
+
+type articleState struct {
+	*Article
+	// Plain text of the article's body, indexed for full-text search.
+	Text string `json:"text"`
+}
+
+// saveRevisions persists the state of retained revisions.
+// Must be called with buildLock held.
+func (s *WebServer) saveRevisions() error {
+	s.revisionLock.RLock()
+	revisions := append([]*Revision{}, s.revisions...)
+	s.revisionLock.RUnlock()
+
+	state := revisionStateFile{Version: revisionStateVersion}
+	for _, revision := range revisions {
+		saved := revisionState{
+			Hash:    revision.Hash,
+			Sources: revision.Sources,
+			BuiltAt: revision.BuiltAt,
+			Changes: revision.Changes,
+		}
+		for key, article := range revision.Articles {
+			saved.Articles = append(saved.Articles, articleState{article, revision.Index.text(key)})
+		}
+		state.Revisions = append(state.Revisions, saved)
+	}
+
+	content, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode state of revisions: %v", err)
+	}
+
+	// The state is replaced atomically, so that it is never read partially written.
+	partialPath := s.env.RevisionStatePath() + PartialFileSuffix
+	if err := os.WriteFile(partialPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write state of revisions: %v", err)
+	}
+	if err := os.Rename(partialPath, s.env.RevisionStatePath()); err != nil {
+		return fmt.Errorf("failed to write state of revisions: %v", err)
+	}
+	return nil
+}
+
+// restoreRevisions restores retained revisions persisted by a previous run of the server,
+// and serves the pinned revision, if it is restored, or the latest restored revision.
+// Revisions with missing compiled files are not restored. Must be called before
+// periodic tasks are started.
+func (s *WebServer) restoreRevisions() error {
+	content, err := os.ReadFile(s.env.RevisionStatePath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state of revisions: %v", err)
+	}
+
+	state := revisionStateFile{}
+	if err := json.Unmarshal(content, &state); err != nil {
+		return fmt.Errorf("failed to decode state of revisions: %v", err)
+	}
+	if state.Version != revisionStateVersion {
+		s.warn("state of revisions ignored: unsupported version %d", state.Version)
+		return nil
+	}
+
+	for _, saved := range state.Revisions {
+		if revision := s.restoreRevision(saved); revision != nil {
+			s.revisions = append(s.revisions, revision)
+		}
+	}
+	if len(s.revisions) == 0 {
+		return nil
+	}
+
+	s.latestRevision = s.revisions[0]
+	if pinned := s.retainedRevision(s.pinnedHash); pinned != nil {
+		s.latestRevision = pinned
+	}
+	s.restored = true
+	return nil
+}
+
+// restoreRevision rebuilds the revision from its persisted state,
+// or returns nil if the revision cannot be served.
+func (s *WebServer) restoreRevision(saved revisionState) *Revision {
+	if len(saved.Articles) == 0 {
+		s.warn("revision %s not restored: no articles found", saved.Hash)
+		return nil
+	}
+
+	revision := NewRevision()
+	revision.Hash = saved.Hash
+	revision.Sources = saved.Sources
+	revision.BuiltAt = saved.BuiltAt
+	revision.Changes = saved.Changes
+
+	for _, article := range saved.Articles {
+		compiledPath := s.env.CompiledTemplatePath(compiledHTMLTemplate(article.ContentHash))
+		if _, err := os.Stat(compiledPath); err != nil {
+			s.warn("revision %s not restored: compiled file of article %s not found", saved.Hash, article.Key)
+			return nil
+		}
+		revision.addArticle(article.Article, article.Text)
+	}
+
+	s.organizeRevision(revision)
+	s.generateChangesFeed(revision)
+	s.log("revision %s built at %s restored: %d articles", revision.Hash, revision.BuiltAt.Format(time.RFC3339), len(revision.Articles))
+	return revision
+}
@@ -0,0 +1,45 @@
+package anduril_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+)
+
+func TestRestoredRevisionReplaced(t *testing.T) {
+	wd, notes, runbooks := t.TempDir(), t.TempDir(), filepath.Join(t.TempDir(), "runbooks")
+	writeArticle(t, notes, "go.md", "Go", "programming")
+	writeArticle(t, mkdirAll(t, runbooks), "deploy.md", "Deploy", "ops")
+	configure := func(config *anduril.Config) {
+		config.Repositories = []anduril.Source{newDirectorySource("notes", notes), newDirectorySource("runbooks", runbooks)}
+	}
+
+	server := newTestServer(t, wd, configure)
+	built := syncSources(t, server, "notes", "runbooks")
+	if len(built.Sources) != 2 {
+		t.Fatalf("unexpected sources: %v", built.Sources)
+	}
+
+	// After a restart, the restored revision is served until every source has been synced.
+	if err := os.RemoveAll(runbooks); err != nil {
+		t.Fatal(err)
+	}
+	server = newTestServer(t, wd, configure)
+	if restored := server.LatestRevision(); restored == nil || restored.Hash != built.Hash || len(restored.Articles) != 2 {
+		t.Fatalf("expected revision %s to be restored, found: %+v", built.Hash, restored)
+	}
+	if revision := syncSources(t, server, "notes"); revision.Hash != built.Hash {
+		t.Fatalf("expected the restored revision %s to be served, found: %s", built.Hash, revision.Hash)
+	}
+
+	// A source which fails to sync is left out.
+	if err := server.SyncSource("runbooks"); err == nil {
+		t.Fatalf("sync runbooks: expected an error")
+	}
+	revision := server.LatestRevision()
+	if len(revision.Sources) != 1 || revision.Sources[0].Name != "notes" || len(revision.Articles) != 1 {
+		t.Fatalf("expected a revision of notes, found: sources: %v articles: %d", revision.Sources, len(revision.Articles))
+	}
+}
@@ -4,75 +4,148 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/cicovic-andrija/anduril/service"
 	"github.com/cicovic-andrija/libgo/fs"
 )
 
-func (s *WebServer) syncRepository(trace service.TraceCallback, v ...interface{}) error {
+// syncSource syncs the repository of the source passed as the first argument, and merges
+// a new revision if the source has changed.
+func (s *WebServer) syncSource(trace service.TraceCallback, v ...interface{}) error {
 	var (
-		found bool
-		err   error
+		source = v[0].(*contentSource)
+		found  bool
+		err    error
 	)
 
 	trace("checking for new content revision...")
 
+	// Only the repository of the source is locked while it is synced, so that a slow
+	// remote does not hold back syncs of other sources, or builds of their revisions.
+	source.lock.Lock()
 	// First iteration will initialize the repository.
-	if s.repository.Empty() {
-		repoRoot := s.env.RepositoryWorkingDirectory()
-		if err = s.repository.Initialize(repoRoot, trace); err != nil {
-			return err
+	if source.repository.Empty() {
+		repoRoot := s.env.SourceWorkingDirectory(source.Name)
+		if err = source.repository.Initialize(repoRoot, trace); err == nil {
+			found = true
 		}
-		found = true
+	} else {
+		found, err = source.repository.Sync()
 	}
-
-	if !found && !s.repository.Empty() {
-		found, err = s.repository.Sync()
-		if err != nil {
-			return err
+	source.lock.Unlock()
+
+	// Compiled files of a revision which is being built are not referenced by the latest
+	// revision yet, and must not be cleaned up in the meantime.
+	s.buildLock.Lock()
+	defer s.buildLock.Unlock()
+
+	firstAttempt := !source.attempted
+	source.attempted = true
+	if err != nil {
+		// The restored revision is replaced only once every source has been synced,
+		// and a source which fails to sync must not hold back the other sources.
+		if firstAttempt && s.buildPending {
+			s.warn("repository %q failed to sync and is left out of the revision which replaces the restored revision: %v", source.Name, err)
+			if err := s.buildRevision(trace); err != nil {
+				s.warn("%v", err)
+			}
 		}
+		return err
 	}
 
 	if found {
-		revision := &Revision{
-			Articles:      make(map[string]*Article),
-			Tags:          make(map[string][]*Article),
-			ContainerPath: s.repository.ContentRoot(),
-			Hash:          s.repository.LatestRevisionID(),
-		}
-		trace("new revision found with hash %s", revision.Hash)
+		trace("new revision found with hash %s", source.repository.LatestRevisionID())
+		return s.buildRevision(trace)
+	}
+
+	return nil
+}
 
-		err := s.processRevision(revision)
-		if err != nil {
-			return fmt.Errorf("failed to process new revision %s: %v", revision.Hash, err)
+// buildRevision merges the latest revisions of all initialized sources into a new revision,
+// and publishes it. Sources which are not initialized yet are left out until
+// their first sync succeeds, unless a revision restored on start-up is served,
+// which is only replaced once every source has been synced at least once.
+// Must be called with buildLock held.
+func (s *WebServer) buildRevision(trace service.TraceCallback) error {
+	revision := NewRevision()
+
+	// Repositories of all sources are read while the revision is built, and must not
+	// be synced in the meantime.
+	for _, source := range s.sources {
+		source.lock.RLock()
+		defer source.lock.RUnlock()
+	}
+
+	contents := []sourceContent{}
+	for _, source := range s.sources {
+		if source.repository.Empty() {
+			if s.restored && !source.attempted {
+				// If the first sync of the source fails, the revision is built without it.
+				trace("repository %q not synced yet, restored revision is served until it is", source.Name)
+				s.buildPending = true
+				return nil
+			}
+			trace("repository %q not initialized yet and left out of the revision", source.Name)
+			continue
 		}
+		contents = append(contents, sourceContent{source, source.repository.ContentRoot()})
+		revision.Sources = append(revision.Sources, SourceRevision{
+			Name: source.Name,
+			Hash: source.repository.LatestRevisionID(),
+		})
+	}
+	revision.Hash = compositeHash(revision.Sources)
+
+	if err := s.processRevision(revision, contents); err != nil {
+		return fmt.Errorf("failed to process new revision %s: %v", revision.Hash, err)
+	}
 
-		s.revisionLock.Lock()
-		s.latestRevision = revision
-		s.revisionLock.Unlock()
-		trace("latest revision updated to %s", revision.Hash)
+	// Revisions are immutable once published, and the previous revision can be read
+	// without holding the lock.
+	s.revisionLock.RLock()
+	var previous *Revision
+	if len(s.revisions) > 0 {
+		previous = s.revisions[0]
 	}
+	s.revisionLock.RUnlock()
+	s.recordChanges(previous, revision)
 
+	s.publishRevision(revision, trace)
+	s.restored = false
+	s.buildPending = false
+
+	if err := s.saveRevisions(); err != nil {
+		s.warn("%v", err)
+	}
 	return nil
 }
 
 func (s *WebServer) cleanUpStaleFiles(trace service.TraceCallback, v ...interface{}) error {
 	trace("checking for stale files ready for cleanup...")
 
+	// Compiled files of historical revisions which are being built are not referenced yet.
+	s.buildLock.Lock()
+	defer s.buildLock.Unlock()
+	s.historyLock.Lock()
+	defer s.historyLock.Unlock()
+
+	// Revisions are pinned and unpinned without holding buildLock.
+	s.revisionLock.RLock()
 	if s.latestRevision == nil {
+		s.revisionLock.RUnlock()
 		trace("aborting search because latest revision is unknown")
 		return nil
 	}
+	referenced := s.referencedCompiledFiles()
+	s.revisionLock.RUnlock()
 
-	latestVersionSuffix := fmt.Sprintf("_%s.html", s.latestRevision.Hash)
 	failed := []string{}
 	cleanedUp := 0
 
 	if err := fs.EnumerateDirectory(
 		filepath.Join(s.env.CompiledWorkDirectory()),
 		func(fileName string) {
-			if !strings.HasSuffix(fileName, latestVersionSuffix) {
+			if !referenced[fileName] {
 				if err := os.Remove(s.env.CompiledTemplatePath(fileName)); err == nil {
 					cleanedUp += 1
 					trace("%s was cleaned up", fileName)
@@ -94,3 +167,19 @@ func (s *WebServer) cleanUpStaleFiles(trace service.TraceCallback, v ...interfac
 	}
 	return nil
 }
+
+// referencedCompiledFiles returns names of compiled files referenced by revisions
+// which can still be served, i.e. all retained revisions and cached historical revisions.
+// Compiled files are shared between revisions, and are identified by a hash of the data
+// file contents. Must be called with revisionLock held.
+func (s *WebServer) referencedCompiledFiles() map[string]bool {
+	referenced := make(map[string]bool)
+	revisions := append([]*Revision{}, s.revisions...)
+	revisions = append(revisions, s.history.Revisions()...)
+	for _, revision := range revisions {
+		for _, article := range revision.Articles {
+			referenced[compiledHTMLTemplate(article.ContentHash)] = true
+		}
+	}
+	return referenced
+}
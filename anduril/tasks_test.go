@@ -0,0 +1,79 @@
+package anduril_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+	"github.com/cicovic-andrija/anduril/service"
+)
+
+// referencedFiles returns the sorted names of compiled files referenced by the revisions.
+func referencedFiles(revisions ...*anduril.Revision) []string {
+	referenced := make(map[string]bool)
+	for _, revision := range revisions {
+		for _, article := range revision.Articles {
+			referenced[article.ContentHash+".html"] = true
+		}
+	}
+	names := []string{}
+	for name := range referenced {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCleanUpStaleFiles(t *testing.T) {
+	wd := t.TempDir()
+	notes := t.TempDir()
+	server := newTestServer(t, wd, func(config *anduril.Config) {
+		config.Settings.RetainedRevisions = 2
+		config.Repositories = []anduril.Source{newDirectorySource("notes", notes)}
+	})
+
+	// Nothing is cleaned up before the first revision is built.
+	stale := filepath.Join(service.NewEnvironment(wd).CompiledWorkDirectory(), "stale.html")
+	if err := os.WriteFile(stale, []byte("<p>stale</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.CleanUpStaleFiles(); err != nil {
+		t.Fatalf("clean up: %v", err)
+	}
+	if _, err := os.Stat(stale); err != nil {
+		t.Fatalf("stale file: expected to be kept, found: %v", err)
+	}
+
+	writeArticle(t, notes, "go.md", "Go v1", "programming")
+	writeArticle(t, notes, "python.md", "Python", "programming")
+	first := syncSources(t, server, "notes")
+	writeArticle(t, notes, "go.md", "Go v2", "programming")
+	second := syncSources(t, server, "notes")
+
+	// Files of articles which were changed are still referenced by the retained revision.
+	if err := server.CleanUpStaleFiles(); err != nil {
+		t.Fatalf("clean up: %v", err)
+	}
+	if expected, found := referencedFiles(first, second), compiledFiles(t, wd); !reflect.DeepEqual(expected, found) {
+		t.Fatalf("compiled files: expected: %v found: %v", expected, found)
+	}
+
+	// Once the revision is no longer retained, its files are cleaned up.
+	if err := os.Remove(filepath.Join(notes, "python.md")); err != nil {
+		t.Fatal(err)
+	}
+	third := syncSources(t, server, "notes")
+	if err := server.CleanUpStaleFiles(); err != nil {
+		t.Fatalf("clean up: %v", err)
+	}
+	if expected, found := referencedFiles(second, third), compiledFiles(t, wd); !reflect.DeepEqual(expected, found) {
+		t.Fatalf("compiled files: expected: %v found: %v", expected, found)
+	}
+	removed := service.NewEnvironment(wd).CompiledTemplatePath(first.GetArticle("go").ContentHash + ".html")
+	if _, err := os.Stat(removed); !os.IsNotExist(err) {
+		t.Fatalf("compiled files: expected %s to be cleaned up, found: %v", removed, err)
+	}
+}
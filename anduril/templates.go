@@ -0,0 +1,92 @@
+package anduril
+
+import (
+	"html/template"
+	"os"
+	"sync"
+	"time"
+)
+
+// TemplateCache holds parsed page templates, keyed by template name and revision hash.
+// A cached template is parsed again if any of the files it was parsed from changes on disk.
+type TemplateCache struct {
+	lock      *sync.RWMutex
+	templates map[string]*cachedTemplate
+}
+
+type cachedTemplate struct {
+	template *template.Template
+	files    []string
+	modTimes []time.Time
+}
+
+func NewTemplateCache() *TemplateCache {
+	return &TemplateCache{
+		lock:      &sync.RWMutex{},
+		templates: make(map[string]*cachedTemplate),
+	}
+}
+
+// Get returns the cached template for key if it is still up to date with files,
+// otherwise it calls parse and caches the result.
+func (c *TemplateCache) Get(key string, files []string, parse func() (*template.Template, error)) (*template.Template, error) {
+	c.lock.RLock()
+	cached, found := c.templates[key]
+	c.lock.RUnlock()
+	if found && !cached.isStale() {
+		return cached.template, nil
+	}
+
+	// Modification times are collected before parsing, so that a change made
+	// while parsing is detected on the next lookup.
+	modTimes, err := modificationTimes(files)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := parse()
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	c.templates[key] = &cachedTemplate{
+		template: t,
+		files:    files,
+		modTimes: modTimes,
+	}
+	c.lock.Unlock()
+	return t, nil
+}
+
+// Invalidate removes all templates from the cache.
+func (c *TemplateCache) Invalidate() {
+	c.lock.Lock()
+	c.templates = make(map[string]*cachedTemplate)
+	c.lock.Unlock()
+}
+
+func (t *cachedTemplate) isStale() bool {
+	modTimes, err := modificationTimes(t.files)
+	if err != nil {
+		return true
+	}
+	for i := range modTimes {
+		if !modTimes[i].Equal(t.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func modificationTimes(files []string) ([]time.Time, error) {
+	modTimes := make([]time.Time, len(files))
+	for i, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
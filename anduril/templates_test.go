@@ -0,0 +1,117 @@
+package anduril_test
+
+import (
+	"errors"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+)
+
+// templateParser parses the files of a page template, and counts how many times it was called.
+type templateParser struct {
+	files  []string
+	parsed int
+}
+
+func (p *templateParser) parse() (*template.Template, error) {
+	p.parsed++
+	return template.ParseFiles(p.files...)
+}
+
+func executeTemplate(t *testing.T, tmpl *template.Template) string {
+	t.Helper()
+
+	var b strings.Builder
+	if err := tmpl.ExecuteTemplate(&b, "page", nil); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	return b.String()
+}
+
+func writeTemplate(t *testing.T, path string, content string, modTime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTemplateCache(t *testing.T) {
+	dir := t.TempDir()
+	page, content := filepath.Join(dir, "page.html"), filepath.Join(dir, "article.html")
+	modTime := time.Now().Add(-time.Hour)
+	writeTemplate(t, page, `{{define "page"}}<main>{{template "content"}}</main>{{end}}`, modTime)
+	writeTemplate(t, content, `{{define "content"}}v1{{end}}`, modTime)
+
+	cache := anduril.NewTemplateCache()
+	parser := &templateParser{files: []string{page, content}}
+	get := func(key string, expected string, parsed int) {
+		t.Helper()
+		tmpl, err := cache.Get(key, parser.files, parser.parse)
+		if err != nil {
+			t.Fatalf("%s: get: %v", key, err)
+		}
+		if found := executeTemplate(t, tmpl); found != expected {
+			t.Fatalf("%s: expected: %s found: %s", key, expected, found)
+		}
+		if parser.parsed != parsed {
+			t.Fatalf("%s: parsed: expected: %d found: %d", key, parsed, parser.parsed)
+		}
+	}
+
+	// Templates are cached by content template and revision hash.
+	get("article@aaaaaaaaaa", "<main>v1</main>", 1)
+	get("article@aaaaaaaaaa", "<main>v1</main>", 1)
+	get("article@bbbbbbbbbb", "<main>v1</main>", 2)
+	get("article@aaaaaaaaaa", "<main>v1</main>", 2)
+
+	// A template is parsed again once any of its files is modified.
+	writeTemplate(t, content, `{{define "content"}}v2{{end}}`, modTime.Add(time.Minute))
+	get("article@aaaaaaaaaa", "<main>v2</main>", 3)
+	get("article@aaaaaaaaaa", "<main>v2</main>", 3)
+	writeTemplate(t, page, `{{define "page"}}<article>{{template "content"}}</article>{{end}}`, modTime.Add(time.Minute))
+	get("article@aaaaaaaaaa", "<article>v2</article>", 4)
+
+	cache.Invalidate()
+	get("article@aaaaaaaaaa", "<article>v2</article>", 5)
+	get("article@bbbbbbbbbb", "<article>v2</article>", 6)
+}
+
+func TestTemplateCacheErrors(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "page.html")
+	writeTemplate(t, page, `{{define "page"}}page{{end}}`, time.Now())
+
+	cache := anduril.NewTemplateCache()
+	parsed := 0
+	failing := func() (*template.Template, error) {
+		parsed++
+		return nil, errors.New("parse error")
+	}
+
+	// Failed parses are not cached.
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Get("page@aaaaaaaaaa", []string{page}, failing); err == nil {
+			t.Fatalf("get: expected a parse error")
+		}
+	}
+	if parsed != 2 {
+		t.Fatalf("parsed: expected: 2 found: %d", parsed)
+	}
+
+	// Templates with missing files are not parsed.
+	if _, err := cache.Get("page@aaaaaaaaaa", []string{filepath.Join(dir, "missing.html")}, failing); !os.IsNotExist(err) {
+		t.Fatalf("get: expected a not exist error, found: %v", err)
+	}
+	if parsed != 2 {
+		t.Fatalf("parsed: expected: 2 found: %d", parsed)
+	}
+}
@@ -0,0 +1,327 @@
+package anduril
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/cicovic-andrija/anduril/repository"
+)
+
+// Time-travel URLs serve articles and tags as they were in a past revision:
+//
+//	/r/{hash}/articles/{key}
+//	/r/{hash}/tags/{tag}
+//
+// The hash identifies either a retained revision, by its hash or the revision of one of its
+// sources, or a commit in the history of one of the git repositories. In the latter case, a
+// revision of that repository alone is built on demand from the commit, and cached.
+
+const TimeTravelPathPrefix = "/r/"
+
+// Revision hashes in URLs are abbreviated or full commit hashes, or revision hashes.
+var revisionIDPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// Maximum number of revision IDs remembered as not found; when the limit is reached,
+// all of them are forgotten.
+const RevisionMissLimit = 1024
+
+// ErrHistoryBusy is returned when a historical revision cannot be built,
+// because another historical revision is being built.
+var ErrHistoryBusy = errors.New("another historical revision is being built")
+
+// RevisionCache holds a bounded number of revisions built on demand,
+// and evicts the least recently used revision when it is full.
+// IDs of revisions which were not found in history are cached as well.
+type RevisionCache struct {
+	lock      *sync.Mutex
+	capacity  int
+	revisions []*Revision
+	misses    map[string]bool
+}
+
+func NewRevisionCache(capacity int) *RevisionCache {
+	return &RevisionCache{
+		lock:     &sync.Mutex{},
+		capacity: capacity,
+		misses:   make(map[string]bool),
+	}
+}
+
+// Missing reports whether the revision identified by id was not found in history.
+func (c *RevisionCache) Missing(id string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.misses[id]
+}
+
+// AddMiss remembers that the revision identified by id was not found in history.
+func (c *RevisionCache) AddMiss(id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.misses) >= RevisionMissLimit {
+		c.misses = make(map[string]bool)
+	}
+	c.misses[id] = true
+}
+
+// ClearMisses forgets revisions which were not found in history,
+// which might be found after the repositories are synced.
+func (c *RevisionCache) ClearMisses() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.misses = make(map[string]bool)
+}
+
+// Find returns the cached revision identified by id, or nil if it is not cached.
+func (c *RevisionCache) Find(id string) *Revision {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	i := matchRevision(c.revisions, id)
+	if i < 0 {
+		return nil
+	}
+	revision := c.revisions[i]
+	copy(c.revisions[1:i+1], c.revisions[:i])
+	c.revisions[0] = revision
+	return revision
+}
+
+// Add caches the revision, evicting the least recently used revision if the cache is full.
+func (c *RevisionCache) Add(revision *Revision) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.revisions = append([]*Revision{revision}, c.revisions...)
+	if len(c.revisions) > c.capacity {
+		c.revisions = c.revisions[:c.capacity]
+	}
+}
+
+// Revisions returns all cached revisions.
+func (c *RevisionCache) Revisions() []*Revision {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]*Revision{}, c.revisions...)
+}
+
+// matchRevision returns the index of the first revision identified by id, or -1 if no revision is
+// identified by id. A revision is identified by its own hash, which takes precedence, or by the revision
+// of one of its sources, of which id can be a prefix or an extension. An id which identifies revisions
+// of different commits is ambiguous, and identifies no revision.
+func matchRevision(revisions []*Revision, id string) int {
+	for i, revision := range revisions {
+		if revision.Hash == id {
+			return i
+		}
+	}
+
+	match, commit := -1, ""
+	for i, revision := range revisions {
+		for _, source := range revision.Sources {
+			if !hashMatches(source.Hash, id) {
+				continue
+			}
+			if match < 0 {
+				match, commit = i, source.Hash
+			} else if !hashMatches(source.Hash, commit) {
+				return -1
+			}
+		}
+	}
+	return match
+}
+
+// hashMatches reports whether the commit hash is identified by id, which can be its prefix or extension.
+func hashMatches(hash string, id string) bool {
+	return strings.HasPrefix(id, hash) || strings.HasPrefix(hash, id)
+}
+
+// revisionNotFound answers requests for revisions, and content of revisions, which are not
+// found with the 404 page and status, so that crawlers and scripts walking past revisions
+// can tell them apart.
+func (s *WebServer) revisionNotFound(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	s.PageNotFoundHandler(w, r)
+}
+
+func (s *WebServer) TimeTravelHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(r.URL.Path, "/", 3)
+	if len(parts) != 3 || !revisionIDPattern.MatchString(parts[0]) || parts[2] == "" {
+		s.revisionNotFound(w, r)
+		return
+	}
+	id, kind, key := parts[0], parts[1], parts[2]
+	if kind != "articles" && kind != "tags" {
+		s.revisionNotFound(w, r)
+		return
+	}
+
+	revision, err := s.findRevision(id)
+	if err == ErrHistoryBusy {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		s.warn("failed to find revision %s: %v", id, err)
+	}
+	if revision == nil {
+		s.revisionNotFound(w, r)
+		return
+	}
+
+	s.revisionLock.RLock()
+	served := revision == s.latestRevision
+	s.revisionLock.RUnlock()
+	if served {
+		http.Redirect(w, r, "/"+kind+"/"+key, http.StatusFound)
+		return
+	}
+
+	notice := &HistoricalNotice{
+		RevisionHash: revision.Hash,
+		CurrentPath:  "/" + kind + "/" + key,
+	}
+	linkPrefix := TimeTravelPathPrefix + revision.Hash
+
+	switch kind {
+	case "articles":
+		article := revision.GetArticle(key)
+		if article == nil {
+			s.revisionNotFound(w, r)
+			return
+		}
+		err = s.serveCachedPage(
+			w,
+			r,
+			revision,
+			"r/articles/"+key,
+			lastModified(article),
+			func(w io.Writer) error {
+				page := articlePage(article, revision)
+				page.LinkPrefix = linkPrefix
+				page.Historical = notice
+				return s.renderPage(w, page)
+			},
+		)
+	case "tags":
+		articles := revision.SearchByTag(key)
+		if articles == nil {
+			s.revisionNotFound(w, r)
+			return
+		}
+		err = s.serveCachedPage(
+			w,
+			r,
+			revision,
+			"r/tags/"+key,
+			latestModified(articles),
+			func(w io.Writer) error {
+				page := tagPage(key, articles, revision)
+				page.LinkPrefix = linkPrefix
+				page.Historical = notice
+				return s.renderPage(w, page)
+			},
+		)
+	}
+	if err != nil {
+		s.warn("failed to render %s of revision %s: %v", notice.CurrentPath, revision.Hash, err)
+	}
+}
+
+// findRevision returns the retained or cached revision identified by id, or builds the revision
+// from the history of the repositories. If the revision is not found, nil is returned.
+// Only one historical revision is built at a time, and ErrHistoryBusy is returned
+// instead of waiting for another build to complete.
+func (s *WebServer) findRevision(id string) (*Revision, error) {
+	s.revisionLock.RLock()
+	if i := matchRevision(s.revisions, id); i >= 0 {
+		revision := s.revisions[i]
+		s.revisionLock.RUnlock()
+		return revision, nil
+	}
+	s.revisionLock.RUnlock()
+
+	if revision := s.history.Find(id); revision != nil || s.history.Missing(id) {
+		return revision, nil
+	}
+
+	if !s.historyLock.TryLock() {
+		return nil, ErrHistoryBusy
+	}
+	defer s.historyLock.Unlock()
+
+	// The revision might have been built by another request in the meantime.
+	if revision := s.history.Find(id); revision != nil || s.history.Missing(id) {
+		return revision, nil
+	}
+	revision, err := s.buildHistoricalRevision(id)
+	if revision == nil && err == nil {
+		s.history.AddMiss(id)
+	}
+	return revision, err
+}
+
+// buildHistoricalRevision builds a revision from the content of the commit identified by id,
+// from the first repository which has the commit in its history, and caches it.
+// Must be called with historyLock held.
+func (s *WebServer) buildHistoricalRevision(id string) (*Revision, error) {
+	for _, source := range s.sources {
+		dir, hash, err := s.exportRevision(source, id)
+		if err != nil {
+			return nil, err
+		}
+		if dir == "" {
+			continue
+		}
+
+		revision := NewRevision()
+		revision.historical = true
+		revision.Sources = []SourceRevision{{Name: source.Name, Hash: hash[:10]}}
+		revision.Hash = hash[:10]
+		err = s.processRevision(revision, []sourceContent{{source, dir}})
+		os.RemoveAll(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to process historical revision %s of repository %q: %v", revision.Hash, source.Name, err)
+		}
+
+		s.history.Add(revision)
+		s.trace(RepositoryTag, "historical revision %s of repository %q built and cached", revision.Hash, source.Name)
+		return revision, nil
+	}
+	return nil, nil
+}
+
+// exportRevision exports the content of the commit identified by id from the repository
+// of the source to a new temporary directory, and returns the directory and the full hash
+// of the commit. If the repository does not have the commit, an empty directory path is returned.
+func (s *WebServer) exportRevision(source *contentSource, id string) (string, string, error) {
+	source.lock.RLock()
+	defer source.lock.RUnlock()
+
+	history, ok := source.repository.(repository.History)
+	if !ok || source.repository.Empty() {
+		return "", "", nil
+	}
+
+	dir, err := os.MkdirTemp(s.env.WorkDirectoryPath(), "history-")
+	if err != nil {
+		return "", "", err
+	}
+
+	hash, err := history.ExportRevision(id, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		if err == repository.ErrRevisionNotFound {
+			return "", "", nil
+		}
+		return "", "", err
+	}
+	return dir, hash, nil
+}
@@ -0,0 +1,224 @@
+package anduril_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+	"github.com/cicovic-andrija/anduril/repository"
+	"github.com/cicovic-andrija/libgo/https"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testGitSource is a git repository with articles in the notes directory,
+// from which a source of the local protocol is cloned.
+type testGitSource struct {
+	t    *testing.T
+	dir  string
+	repo *git.Repository
+}
+
+func newTestGitSource(t *testing.T) *testGitSource {
+	// The file transport runs git-upload-pack.
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	source := &testGitSource{t: t, dir: t.TempDir()}
+	var err error
+	if source.repo, err = git.PlainInit(source.dir, false /* bare */); err != nil {
+		t.Fatalf("init repository: %v", err)
+	}
+	return source
+}
+
+// commit writes the article to the repository and commits it, and returns the hash of the commit.
+func (s *testGitSource) commit(fileName string, title string, tags ...string) string {
+	writeArticle(s.t, s.notesDir(), fileName, title, tags...)
+
+	w, err := s.repo.Worktree()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if _, err = w.Add("notes/" + fileName); err != nil {
+		s.t.Fatalf("add %s: %v", fileName, err)
+	}
+	hash, err := w.Commit("Update "+fileName, &git.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		s.t.Fatalf("commit %s: %v", fileName, err)
+	}
+	return hash.String()
+}
+
+func (s *testGitSource) notesDir() string {
+	return mkdirAll(s.t, filepath.Join(s.dir, "notes"))
+}
+
+func (s *testGitSource) config(name string) anduril.Source {
+	return anduril.Source{
+		Name: name,
+		Repository: repository.Config{
+			Protocol:            repository.LocalProtocol,
+			RepoPath:            s.dir,
+			Remote:              "origin",
+			Branch:              "master",
+			RelativeContentPath: "notes",
+		},
+	}
+}
+
+func newRevisionWithSources(hash string, sources ...string) *anduril.Revision {
+	revision := newTestRevision(hash)
+	for i := 0; i+1 < len(sources); i += 2 {
+		revision.Sources = append(revision.Sources, anduril.SourceRevision{Name: sources[i], Hash: sources[i+1]})
+	}
+	return revision
+}
+
+func cachedHashes(cache *anduril.RevisionCache) []string {
+	hashes := []string{}
+	for _, revision := range cache.Revisions() {
+		hashes = append(hashes, revision.Hash)
+	}
+	return hashes
+}
+
+func TestRevisionCache(t *testing.T) {
+	cache := anduril.NewRevisionCache(2)
+	cache.Add(newRevisionWithSources("a", "notes", "aaaaaaa111"))
+	cache.Add(newRevisionWithSources("b", "notes", "bbbbbbb222"))
+	if hashes := strings.Join(cachedHashes(cache), ","); hashes != "b,a" {
+		t.Fatalf("cached revisions: expected: b,a found: %s", hashes)
+	}
+
+	// The least recently used revision is evicted.
+	if revision := cache.Find("aaaaaaa"); revision == nil || revision.Hash != "a" {
+		t.Fatalf("find: expected revision a, found: %v", revision)
+	}
+	cache.Add(newRevisionWithSources("c", "notes", "ccccccc333"))
+	if hashes := strings.Join(cachedHashes(cache), ","); hashes != "c,a" {
+		t.Fatalf("cached revisions: expected: c,a found: %s", hashes)
+	}
+	if revision := cache.Find("bbbbbbb222"); revision != nil {
+		t.Fatalf("find: expected evicted revision b not to be found")
+	}
+
+	cache.AddMiss("ddddddd")
+	if !cache.Missing("ddddddd") || cache.Missing("ddddddd4") {
+		t.Fatalf("missing: expected only ddddddd to be missing")
+	}
+	cache.ClearMisses()
+	if cache.Missing("ddddddd") {
+		t.Fatalf("missing: expected no misses after clear")
+	}
+}
+
+func TestRevisionCacheAmbiguousID(t *testing.T) {
+	cache := anduril.NewRevisionCache(4)
+	cache.Add(newRevisionWithSources("x", "notes", "abcdef1111"))
+	cache.Add(newRevisionWithSources("y", "notes", "abcdef2222"))
+	// Revisions of different sources can share the revision of one of them.
+	cache.Add(newRevisionWithSources("z", "notes", "abcdef1111", "runbooks", "9999999999"))
+
+	for _, test := range []struct {
+		id       string
+		expected string
+	}{
+		{"abcdef", ""},
+		{"abcdef1", "z"},
+		{"abcdef2222", "y"},
+		{"abcdef2222ffffffff", "y"},
+		{"x", "x"},
+		{"9999999", "z"},
+		{"0000000", ""},
+	} {
+		found := ""
+		if revision := cache.Find(test.id); revision != nil {
+			found = revision.Hash
+		}
+		if found != test.expected {
+			t.Fatalf("find %s: expected: %q found: %q", test.id, test.expected, found)
+		}
+	}
+}
+
+func TestTimeTravelHandler(t *testing.T) {
+	source := newTestGitSource(t)
+	server := newTestServer(t, t.TempDir(), func(config *anduril.Config) {
+		config.Settings.RetainedRevisions = 1
+		config.Repositories = []anduril.Source{source.config("notes")}
+	})
+	handler := https.Adapt(http.HandlerFunc(server.TimeTravelHandler), https.StripPrefix(anduril.TimeTravelPathPrefix))
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, anduril.TimeTravelPathPrefix+path, nil))
+		return recorder
+	}
+	expectPage := func(path string, contents ...string) {
+		t.Helper()
+		response := get(path)
+		if response.Code != http.StatusOK {
+			t.Fatalf("%s: expected: %d found: %d", path, http.StatusOK, response.Code)
+		}
+		for _, content := range contents {
+			if !strings.Contains(response.Body.String(), content) {
+				t.Fatalf("%s: %q not found in the page", path, content)
+			}
+		}
+	}
+
+	first := source.commit("go.md", "Go v1", "programming")
+	second := source.commit("go.md", "Go v2", "programming")
+	third := source.commit("go.md", "Go v3", "programming")
+	syncSources(t, server, "notes")
+
+	// The served revision is redirected to the current version of the page.
+	response := get(third[:10] + "/articles/go")
+	if response.Code != http.StatusFound || response.Header().Get("Location") != "/articles/go" {
+		t.Fatalf("served revision: expected a redirect to /articles/go, found: %d %s", response.Code, response.Header().Get("Location"))
+	}
+
+	// Past revisions are built from history, and shown with a banner.
+	banner := "which is not the current version"
+	expectPage(second[:7]+"/articles/go", "Go v2", second[:10], banner)
+	expectPage(second+"/tags/programming", "Go v2", banner)
+
+	for _, path := range []string{
+		second[:10] + "/files/go",
+		second[:10] + "/articles/python",
+		second[:10] + "/tags/python",
+		second[:10] + "/articles/",
+		"0000000/articles/go",
+		"not-a-revision/articles/go",
+	} {
+		if response := get(path); response.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected: %d found: %d", path, http.StatusNotFound, response.Code)
+		}
+	}
+
+	// Only one historical revision is built at a time.
+	unlock := server.LockHistory()
+	response = get(first[:10] + "/articles/go")
+	unlock()
+	if response.Code != http.StatusServiceUnavailable || response.Header().Get("Retry-After") == "" {
+		t.Fatalf("busy: expected: %d found: %d", http.StatusServiceUnavailable, response.Code)
+	}
+	expectPage(first[:10]+"/articles/go", "Go v1", banner)
+
+	// Revisions not found are found once they are published.
+	fourth := source.commit("go.md", "Go v4", "programming")
+	source.commit("go.md", "Go v5", "programming")
+	if response := get(fourth[:10] + "/articles/go"); response.Code != http.StatusNotFound {
+		t.Fatalf("unpublished revision: expected: %d found: %d", http.StatusNotFound, response.Code)
+	}
+	syncSources(t, server, "notes")
+	expectPage(fourth[:10]+"/articles/go", "Go v4", banner)
+}
@@ -0,0 +1,127 @@
+package anduril
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cicovic-andrija/anduril/repository"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Webhook which triggers an immediate repository sync when new commits are pushed
+// to the repository. Both GitHub and Gitea style deliveries are supported.
+
+const (
+	// URL path of the webhook endpoint.
+	GitWebhookPath = "/hooks/git"
+	// Maximum accepted size of a webhook payload.
+	MaxWebhookPayloadSize = 1 << 20
+)
+
+// Headers of webhook deliveries.
+const (
+	GitHubEventHeader     = "X-GitHub-Event"
+	GitHubSignatureHeader = "X-Hub-Signature-256"
+	GiteaEventHeader      = "X-Gitea-Event"
+	GiteaSignatureHeader  = "X-Gitea-Signature"
+)
+
+// Webhook events.
+const (
+	PingEvent = "ping"
+	PushEvent = "push"
+)
+
+type pushPayload struct {
+	Ref string `json:"ref"`
+}
+
+func (s *WebServer) GitWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxWebhookPayloadSize))
+	if err != nil {
+		s.warn("webhook: failed to read payload from %s: %v", r.RemoteAddr, err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if !s.verifyWebhookSignature(r.Header, payload) {
+		s.warn("webhook: rejected delivery from %s: invalid signature", r.RemoteAddr)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	event := r.Header.Get(GitHubEventHeader)
+	if event == "" {
+		event = r.Header.Get(GiteaEventHeader)
+	}
+
+	switch event {
+	case PingEvent:
+		s.trace(RepositoryTag, "webhook: ping received from %s", r.RemoteAddr)
+		w.WriteHeader(http.StatusNoContent)
+	case PushEvent:
+		push := &pushPayload{}
+		if err := json.Unmarshal(payload, push); err != nil {
+			s.warn("webhook: failed to parse push payload: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		// Sources are matched by branch only, so a push may trigger a sync of
+		// other repositories which track the same branch, which is harmless.
+		triggered := []string{}
+		for _, source := range s.sources {
+			if source.Repository.Protocol == repository.FileProtocol {
+				continue
+			}
+			if push.Ref == plumbing.NewBranchReferenceName(source.Repository.Branch).String() {
+				source.triggerSync()
+				triggered = append(triggered, source.Name)
+			}
+		}
+		if len(triggered) == 0 {
+			s.trace(RepositoryTag, "webhook: ignored push to %s", push.Ref)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		s.trace(RepositoryTag, "webhook: push to %s received, triggering sync of repositories %v", push.Ref, triggered)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		s.trace(RepositoryTag, "webhook: ignored event %q", event)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// verifyWebhookSignature reports whether the delivery was signed with the configured secret.
+// GitHub sends the HMAC-SHA256 digest of the payload prefixed with the name of the algorithm,
+// and Gitea sends the bare digest.
+func (s *WebServer) verifyWebhookSignature(header http.Header, payload []byte) bool {
+	signature := header.Get(GitHubSignatureHeader)
+	if signature != "" {
+		if !strings.HasPrefix(signature, "sha256=") {
+			return false
+		}
+		signature = strings.TrimPrefix(signature, "sha256=")
+	} else {
+		signature = header.Get(GiteaSignatureHeader)
+	}
+
+	received, err := hex.DecodeString(signature)
+	if err != nil || len(received) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.settings.WebhookSecret))
+	mac.Write(payload)
+	return hmac.Equal(received, mac.Sum(nil))
+}
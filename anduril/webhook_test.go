@@ -0,0 +1,177 @@
+package anduril_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+	"github.com/cicovic-andrija/anduril/repository"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+// newWebhookTestServer returns a server with git repositories notes and runbooks, which track
+// the master and main branches, and a directory source drafts, which is never synced by webhooks.
+func newWebhookTestServer(t *testing.T) *anduril.WebServer {
+	gitSource := func(name string, branch string) anduril.Source {
+		return anduril.Source{
+			Name: name,
+			Repository: repository.Config{
+				Protocol: repository.LocalProtocol,
+				RepoPath: t.TempDir(),
+				Remote:   "origin",
+				Branch:   branch,
+			},
+		}
+	}
+	return newTestServer(t, t.TempDir(), func(config *anduril.Config) {
+		config.Settings.WebhookSecret = testWebhookSecret
+		config.Repositories = []anduril.Source{
+			gitSource("notes", "master"),
+			gitSource("runbooks", "main"),
+			newDirectorySource("drafts", t.TempDir()),
+		}
+	})
+}
+
+func webhookSignature(secret string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestGitWebhookHandler(t *testing.T) {
+	const (
+		pushMaster = `{"ref": "refs/heads/master"}`
+		pushMain   = `{"ref": "refs/heads/main"}`
+		pushOther  = `{"ref": "refs/heads/drafts"}`
+	)
+
+	for _, test := range []struct {
+		name      string
+		method    string
+		header    map[string]string
+		payload   string
+		code      int
+		triggered []string
+	}{
+		{
+			name:   "github push",
+			method: http.MethodPost,
+			header: map[string]string{
+				anduril.GitHubEventHeader:     anduril.PushEvent,
+				anduril.GitHubSignatureHeader: "sha256=" + webhookSignature(testWebhookSecret, pushMaster),
+			},
+			payload:   pushMaster,
+			code:      http.StatusAccepted,
+			triggered: []string{"notes"},
+		},
+		{
+			name:   "gitea push",
+			method: http.MethodPost,
+			header: map[string]string{
+				anduril.GiteaEventHeader:     anduril.PushEvent,
+				anduril.GiteaSignatureHeader: webhookSignature(testWebhookSecret, pushMain),
+			},
+			payload:   pushMain,
+			code:      http.StatusAccepted,
+			triggered: []string{"runbooks"},
+		},
+		{
+			name:   "push to an untracked branch",
+			method: http.MethodPost,
+			header: map[string]string{
+				anduril.GitHubEventHeader:     anduril.PushEvent,
+				anduril.GitHubSignatureHeader: "sha256=" + webhookSignature(testWebhookSecret, pushOther),
+			},
+			payload: pushOther,
+			code:    http.StatusNoContent,
+		},
+		{
+			name:   "ping",
+			method: http.MethodPost,
+			header: map[string]string{
+				anduril.GitHubEventHeader:     anduril.PingEvent,
+				anduril.GitHubSignatureHeader: "sha256=" + webhookSignature(testWebhookSecret, "{}"),
+			},
+			payload: "{}",
+			code:    http.StatusNoContent,
+		},
+		{
+			name:   "github signature without algorithm",
+			method: http.MethodPost,
+			header: map[string]string{
+				anduril.GitHubEventHeader:     anduril.PushEvent,
+				anduril.GitHubSignatureHeader: webhookSignature(testWebhookSecret, pushMaster),
+			},
+			payload: pushMaster,
+			code:    http.StatusForbidden,
+		},
+		{
+			name:   "signed with another secret",
+			method: http.MethodPost,
+			header: map[string]string{
+				anduril.GiteaEventHeader:     anduril.PushEvent,
+				anduril.GiteaSignatureHeader: webhookSignature("other-secret", pushMaster),
+			},
+			payload: pushMaster,
+			code:    http.StatusForbidden,
+		},
+		{
+			name:   "signature of another payload",
+			method: http.MethodPost,
+			header: map[string]string{
+				anduril.GitHubEventHeader:     anduril.PushEvent,
+				anduril.GitHubSignatureHeader: "sha256=" + webhookSignature(testWebhookSecret, pushMain),
+			},
+			payload: pushMaster,
+			code:    http.StatusForbidden,
+		},
+		{
+			name:    "missing signature",
+			method:  http.MethodPost,
+			header:  map[string]string{anduril.GitHubEventHeader: anduril.PushEvent},
+			payload: pushMaster,
+			code:    http.StatusForbidden,
+		},
+		{
+			name:   "get",
+			method: http.MethodGet,
+			header: map[string]string{
+				anduril.GitHubEventHeader:     anduril.PushEvent,
+				anduril.GitHubSignatureHeader: "sha256=" + webhookSignature(testWebhookSecret, pushMaster),
+			},
+			payload: pushMaster,
+			code:    http.StatusMethodNotAllowed,
+		},
+	} {
+		server := newWebhookTestServer(t)
+		request := httptest.NewRequest(test.method, "https://localhost"+anduril.GitWebhookPath, strings.NewReader(test.payload))
+		for name, value := range test.header {
+			request.Header.Set(name, value)
+		}
+		response := httptest.NewRecorder()
+		server.GitWebhookHandler(response, request)
+
+		if response.Code != test.code {
+			t.Fatalf("%s: expected: %d found: %d", test.name, test.code, response.Code)
+		}
+		if test.code == http.StatusMethodNotAllowed && response.Header().Get("Allow") != http.MethodPost {
+			t.Fatalf("%s: Allow header not set", test.name)
+		}
+		for _, name := range []string{"notes", "runbooks", "drafts"} {
+			expected := false
+			for _, triggered := range test.triggered {
+				expected = expected || triggered == name
+			}
+			if found := server.SyncTriggered(name); found != expected {
+				t.Fatalf("%s: sync of %s triggered: expected: %v found: %v", test.name, name, expected, found)
+			}
+		}
+	}
+}
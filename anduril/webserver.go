@@ -3,12 +3,12 @@ package anduril
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
 	"time"
 
-	"github.com/cicovic-andrija/anduril/repository"
 	"github.com/cicovic-andrija/anduril/service"
 	"github.com/cicovic-andrija/libgo/https"
 	"github.com/cicovic-andrija/libgo/logging"
@@ -18,10 +18,20 @@ type WebServer struct {
 	env            *service.Environment
 	settings       Settings
 	httpsServer    *https.HTTPSServer
-	repository     repository.Repository
+	sources        []*contentSource
 	latestRevision *Revision
+	revisions      []*Revision
+	pinnedHash     string
+	restored       bool
+	buildPending   bool
+	history        *RevisionCache
 	revisionLock   *sync.RWMutex
-	executor       *Executor
+	buildLock      *sync.Mutex
+	historyLock    *sync.Mutex
+	converter      MarkdownConverter
+	templates      *TemplateCache
+	assets         *AssetServer
+	allowOnlyGET   bool
 	taskWaitGroup  *sync.WaitGroup
 	stopChannels   []chan struct{}
 	logger         *logging.FileLog
@@ -37,6 +47,11 @@ func NewWebServer(env *service.Environment, config *Config) (server *WebServer,
 		return nil, errors.New("config cannot be null")
 	}
 
+	siteURLDerived := config.Settings.SiteURL == ""
+	if siteURLDerived {
+		config.Settings.SiteURL = config.DefaultSiteURL()
+	}
+
 	if err := config.Settings.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid setting: %v", err)
 	}
@@ -48,33 +63,79 @@ func NewWebServer(env *service.Environment, config *Config) (server *WebServer,
 
 	config.HTTPS.LogsDirectory = env.LogsDirectoryPath()
 	config.HTTPS.FileServer.Directory = env.AssetsDataDirectory()
+
+	// Assets are served by the web server instead of the file server built into
+	// the HTTPS server, so that they can be served compressed.
+	var assets *AssetServer
+	if config.HTTPS.EnableFileServer {
+		assets = NewAssetServer(config.HTTPS.FileServer)
+		config.HTTPS.EnableFileServer = false
+	}
+
+	// Restricting request methods is also taken over by the web server, because
+	// the webhook endpoint accepts POST requests.
+	allowOnlyGET := config.HTTPS.AllowOnlyGETRequests
+	config.HTTPS.AllowOnlyGETRequests = false
+
 	httpsServer, err := https.NewServer(&config.HTTPS)
 	if err != nil {
 		return nil, fmt.Errorf("failed to init HTTPS server: %v", err)
 	}
 
 	webServer := &WebServer{
-		env:         env,
-		settings:    config.Settings,
-		httpsServer: httpsServer,
-		logger:      logger,
+		env:          env,
+		settings:     config.Settings,
+		httpsServer:  httpsServer,
+		assets:       assets,
+		allowOnlyGET: allowOnlyGET,
+		logger:       logger,
 	}
 
-	gitRepo := &repository.GitRepository{
-		Config: config.Repository,
+	if siteURLDerived {
+		webServer.warn("site_url: not set, feeds and the sitemap link to %s; set it to the public URL of the site", webServer.settings.SiteURL)
 	}
-	if err = gitRepo.Validate(); err != nil {
+
+	legacy, err := config.MigrateLegacyRepository()
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository configuration: %v", err)
+	}
+	if legacy {
+		webServer.warn("repository: deprecated, synced as the repository %q; move it into the repositories list", LegacySourceName)
+	}
+
+	sources, err := newContentSources(config.Repositories, config.Settings.RepositorySyncPeriodDur)
+	if err != nil {
 		return nil, fmt.Errorf("invalid repository configuration: %v", err)
 	} else {
-		webServer.repository = gitRepo
+		webServer.sources = sources
 	}
+	webServer.migrateLegacyWorkingDirectory(legacy)
 
 	// Explicitly set to nil: not initialized.
 	webServer.latestRevision = nil
 	webServer.revisionLock = &sync.RWMutex{}
+	webServer.buildLock = &sync.Mutex{}
+	webServer.historyLock = &sync.Mutex{}
+	webServer.templates = NewTemplateCache()
+	webServer.history = NewRevisionCache(webServer.settings.HistoricalRevisions)
+
+	if err := webServer.loadRevisionPin(); err != nil {
+		return nil, err
+	}
 
-	webServer.executor = &Executor{
-		trace: webServer.generateTraceCallback(ExecutorTag),
+	if err := webServer.restoreRevisions(); err != nil {
+		webServer.warn("revisions not restored: %v", err)
+	}
+
+	switch webServer.settings.MarkdownConverter {
+	case PandocConverter:
+		executor, err := NewExecutor(webServer.generateTraceCallback(ExecutorTag))
+		if err != nil {
+			return nil, err
+		}
+		webServer.converter = executor
+	case BuiltinConverter:
+		webServer.converter = NewNativeConverter(webServer.generateTraceCallback(ConverterTag))
 	}
 
 	return webServer, nil
@@ -85,9 +146,22 @@ func (s *WebServer) ListenAndServe() {
 	s.log("pid: %d", s.env.PID())
 	s.log("working directory: %s", s.env.WDP())
 	s.log("config: %s", s.env.ConfigInfo())
+	s.log("markdown converter: %s (%s)", s.converter.Name(), s.converter.Version())
 	s.log("primary log location: %s", s.logger.LogPath())
 	s.log("HTTPS server log location: %s", s.httpsServer.GetLogPath())
 	s.log("HTTPS requests log location: %s", s.httpsServer.GetRequestsLogPath())
+	for _, source := range s.sources {
+		if source.Repository.CloneDepth > 0 {
+			s.log("repository %q is shallow (depth %d): past revisions and article history are limited to its cloned commits", source.Name, source.Repository.CloneDepth)
+		}
+	}
+	if s.pinnedHash != "" {
+		s.log("served revision is pinned to %s", s.pinnedHash)
+	}
+	if s.latestRevision != nil {
+		s.log("serving restored revision %s until repositories are synced", s.latestRevision.Hash)
+	}
+	s.precompressAssets()
 	s.startPeriodicTasks()
 	s.listenAndServeInternal()
 }
@@ -95,6 +169,8 @@ func (s *WebServer) ListenAndServe() {
 func (s *WebServer) listenAndServeInternal() {
 	// First, register handlers.
 	s.registerHandlers()
+	s.registerAssetHandlers()
+	s.registerAdminHandlers()
 
 	// Start accepting HTTPS connections.
 	httpsErrorChannel := make(chan error, 1)
@@ -119,8 +195,9 @@ func (s *WebServer) listenAndServeInternal() {
 }
 
 func (s *WebServer) startPeriodicTasks() {
+	// One sync task per source, and the tasks started below it.
 	// Increment by 1 when implementing a new periodic task.
-	const N = 2
+	N := len(s.sources) + 1
 
 	s.taskWaitGroup = &sync.WaitGroup{}
 	s.taskWaitGroup.Add(N)
@@ -131,24 +208,30 @@ func (s *WebServer) startPeriodicTasks() {
 	}
 
 	taskN := 0
-	startTask := func(task service.Task, period time.Duration, tag TraceTag, v ...interface{}) {
+	startTask := func(task service.Task, period time.Duration, trigger <-chan struct{}, tag TraceTag, v ...interface{}) {
 		if taskN == N {
 			panic(s.error("attempted to start too many periodic tasks"))
 		}
-		go s.genericPeriodicTask(task, period, s.stopChannels[taskN], tag, v...)
+		go s.genericPeriodicTask(task, period, trigger, s.stopChannels[taskN], tag, v...)
 		taskN++
 	}
 
 	// Start all periodic tasks from here.
-	startTask(s.syncRepository, s.settings.RepositorySyncPeriodDur, RepositoryTag)
-	startTask(s.cleanUpStaleFiles, s.settings.StaleFileCleanupPeriodDur, CleanupTag)
+	// Sources are synced right away, instead of after the first period.
+	for _, source := range s.sources {
+		source.triggerSync()
+		startTask(s.syncSource, source.SyncPeriodDur, source.trigger, source.traceTag(), source)
+	}
+	startTask(s.cleanUpStaleFiles, s.settings.StaleFileCleanupPeriodDur, nil, CleanupTag)
 
 	if taskN != N {
 		panic(s.error("not enough periodic tasks started: expected %d, started %d", N, taskN))
 	}
 }
 
-func (s *WebServer) genericPeriodicTask(task service.Task, period time.Duration, stop chan struct{}, tag TraceTag, v ...interface{}) {
+// genericPeriodicTask runs the task on every tick of the period, and whenever it is
+// triggered through the trigger channel. A nil trigger channel is never triggered.
+func (s *WebServer) genericPeriodicTask(task service.Task, period time.Duration, trigger <-chan struct{}, stop chan struct{}, tag TraceTag, v ...interface{}) {
 	s.log("starting periodic task [%s] with period of %v", tag, period)
 	trace := s.generateTraceCallback(tag)
 	ticker := time.NewTicker(period)
@@ -159,6 +242,13 @@ func (s *WebServer) genericPeriodicTask(task service.Task, period time.Duration,
 			if err != nil {
 				s.error("periodic task [%s] failed with error: %v", tag, err)
 			}
+		case <-trigger:
+			trace("periodic task triggered")
+			err := task(trace, v...)
+			if err != nil {
+				s.error("periodic task [%s] failed with error: %v", tag, err)
+			}
+			ticker.Reset(period)
 		case <-stop:
 			s.taskWaitGroup.Done()
 			s.log("periodic task [%s] stopped", tag)
@@ -173,3 +263,32 @@ func (s *WebServer) stopPeriodicTasks() {
 	}
 	s.taskWaitGroup.Wait()
 }
+
+// handle registers the handler for the given pattern with the HTTPS server.
+// All responses are compressed, if the client supports it.
+func (s *WebServer) handle(pattern string, h http.Handler) {
+	h = https.Adapt(h, s.CompressResponse)
+	if s.allowOnlyGET {
+		h = https.Adapt(h, s.httpsServer.AllowOnlyGET)
+	}
+	s.httpsServer.Handle(pattern, h)
+}
+
+// precompressAssets compresses text assets ahead of time; assets which fail to
+// precompress are served compressed on the fly.
+func (s *WebServer) precompressAssets() {
+	if s.assets == nil {
+		return
+	}
+	names, err := s.assets.Precompress()
+	if err != nil {
+		s.warn("failed to precompress assets: %v", err)
+		return
+	}
+	s.log("precompressed assets: %v", names)
+}
+
+// absoluteURL returns the absolute URL of a resource identified by path on this website.
+func (s *WebServer) absoluteURL(path string) string {
+	return s.settings.SiteURL + path
+}
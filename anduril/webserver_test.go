@@ -0,0 +1,104 @@
+package anduril_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/anduril"
+	"github.com/cicovic-andrija/anduril/repository"
+	"github.com/cicovic-andrija/anduril/service"
+	"github.com/cicovic-andrija/libgo/https"
+)
+
+const testSiteURL = "https://www.example.com"
+
+// newTestServer returns a web server which runs in the working directory wd, with templates
+// of the repository and the builtin markdown converter. The configuration can be adjusted with
+// configure; by default, a single source named "notes" is served from a new empty directory.
+func newTestServer(t *testing.T, wd string, configure func(config *anduril.Config)) *anduril.WebServer {
+	t.Helper()
+
+	templates, err := filepath.Abs(filepath.Join("..", "assets", "templates"))
+	if err != nil {
+		t.Fatalf("templates: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(wd, "data"), 0755); err != nil {
+		t.Fatalf("data directory: %v", err)
+	}
+	if err := os.Symlink(templates, filepath.Join(wd, "data", "templates")); err != nil && !os.IsExist(err) {
+		t.Fatalf("templates: %v", err)
+	}
+
+	env := service.NewEnvironment(wd)
+	if err := env.Initialize(); err != nil {
+		t.Fatalf("environment: %v", err)
+	}
+
+	// Certificates are only read when the server starts listening.
+	certPath, keyPath := filepath.Join(wd, "cert.pem"), filepath.Join(wd, "key.pem")
+	for _, path := range []string{certPath, keyPath} {
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatalf("certificate: %v", err)
+		}
+	}
+
+	config := &anduril.Config{
+		HTTPS: https.Config{
+			Network: https.NetworkConfig{
+				IPAcceptHost: "localhost",
+				TCPPort:      8443,
+				TLSCertPath:  certPath,
+				TLSKeyPath:   keyPath,
+			},
+		},
+		Settings: anduril.Settings{
+			SiteURL:                testSiteURL,
+			MarkdownConverter:      anduril.BuiltinConverter,
+			RepositorySyncPeriod:   "1h",
+			StaleFileCleanupPeriod: "1h",
+		},
+		Repositories: []anduril.Source{newDirectorySource("notes", t.TempDir())},
+	}
+	if configure != nil {
+		configure(config)
+	}
+
+	server, err := anduril.NewWebServer(env, config)
+	if err != nil {
+		t.Fatalf("new web server: %v", err)
+	}
+	return server
+}
+
+// newDirectorySource returns a source served straight from the directory.
+func newDirectorySource(name string, directory string) anduril.Source {
+	return anduril.Source{
+		Name: name,
+		Repository: repository.Config{
+			Protocol: repository.FileProtocol,
+			RepoPath: directory,
+		},
+	}
+}
+
+// writeArticle writes a data file with the title and tags to the directory.
+func writeArticle(t *testing.T, directory string, fileName string, title string, tags ...string) {
+	t.Helper()
+
+	content := fmt.Sprintf("---\ntitle: %s\ntags: [%s]\ncreated: 2023-01-02T15:04:05Z\n---\n\nBody of %s.\n", title, strings.Join(tags, ", "), title)
+	if err := os.WriteFile(filepath.Join(directory, fileName), []byte(content), 0644); err != nil {
+		t.Fatalf("write article: %v", err)
+	}
+}
+
+func mkdirAll(t *testing.T, path string) string {
+	t.Helper()
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	return path
+}
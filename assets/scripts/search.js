@@ -1,5 +1,92 @@
+const searchText = document.getElementById("search-text")
+const searchResults = document.getElementById("search-results")
+const suggestionLimit = 8
+const suggestionDelay = 150
+
+let suggestionTimer = null
+let selectedSuggestion = -1
+
 document.addEventListener("keyup", (e) => {
     if (e.key == "/") {
-        document.getElementById("search-text").focus()
+        searchText.focus()
     }
 })
+
+searchText.addEventListener("input", () => {
+    clearTimeout(suggestionTimer)
+    suggestionTimer = setTimeout(fetchSuggestions, suggestionDelay)
+})
+
+searchText.addEventListener("keydown", (e) => {
+    const links = searchResults.querySelectorAll("a")
+    if (e.key == "ArrowDown" || e.key == "ArrowUp") {
+        e.preventDefault()
+        if (links.length == 0) {
+            return
+        }
+        selectedSuggestion += e.key == "ArrowDown" ? 1 : -1
+        selectedSuggestion = (selectedSuggestion + links.length) % links.length
+        links.forEach((link, i) => link.classList.toggle("selected", i == selectedSuggestion))
+    } else if (e.key == "Enter" && selectedSuggestion >= 0 && selectedSuggestion < links.length) {
+        e.preventDefault()
+        window.location.href = links[selectedSuggestion].href
+    } else if (e.key == "Escape") {
+        hideSuggestions()
+    }
+})
+
+searchText.addEventListener("blur", () => {
+    // Delay hiding so that a click on a suggestion can still be handled.
+    setTimeout(hideSuggestions, suggestionDelay)
+})
+
+function fetchSuggestions() {
+    const query = searchText.value.trim()
+    if (query == "") {
+        hideSuggestions()
+        return
+    }
+
+    const params = new URLSearchParams({q: query, limit: suggestionLimit})
+    fetch("/api/search/suggest?" + params.toString())
+        .then((response) => response.ok ? response.json() : [])
+        .then((suggestions) => {
+            // Ignore responses to queries which are no longer current.
+            if (searchText.value.trim() == query) {
+                showSuggestions(suggestions)
+            }
+        })
+        .catch(() => hideSuggestions())
+}
+
+function showSuggestions(suggestions) {
+    if (suggestions.length == 0) {
+        hideSuggestions()
+        return
+    }
+
+    const list = document.createElement("ul")
+    for (const suggestion of suggestions) {
+        const link = document.createElement("a")
+        link.href = suggestion.url
+        link.textContent = suggestion.title
+        if (suggestion.kind == "tag") {
+            const kind = document.createElement("small")
+            kind.textContent = " | tag"
+            link.appendChild(kind)
+        }
+        const item = document.createElement("li")
+        item.appendChild(link)
+        list.appendChild(item)
+    }
+
+    selectedSuggestion = -1
+    searchResults.replaceChildren(list)
+    searchResults.classList.add("visible")
+}
+
+function hideSuggestions() {
+    selectedSuggestion = -1
+    searchResults.replaceChildren()
+    searchResults.classList.remove("visible")
+}
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -9,16 +11,35 @@ import (
 const (
 	HTTPSProtocol = "https"
 	SSHProtocol   = "ssh"
+	FileProtocol  = "file"
+	LocalProtocol = "local"
 )
 
+// Config configures a repository. With the file protocol, RepoPath is the absolute path
+// of a local directory which is served as is, and Host, Remote and Branch are not used.
+// With the local protocol, RepoPath is either a file:// URL or an absolute path of a git
+// repository (usually bare) on the local file system, and Host is not used.
+// CloneDepth limits the history of git repositories to the given number of latest commits
+// (0 means full history); shallow repositories are synced with shallow fetches and reset to the
+// remote branch, regardless of the sync strategy. SparseCheckout restricts the checkout
+// to RelativeContentPath, and is supported only with the reset sync strategy.
+// TrustedPGPKeyRing is the path of a file with armored OpenPGP public keys, and TrustedSSHKeys
+// is a list of SSH public keys in the authorized_keys format; if either is set, git repositories
+// advance only to commits signed with one of the trusted keys.
 type Config struct {
-	Protocol            string        `json:"protocol"`
-	Host                string        `json:"host"`
-	RepoPath            string        `json:"repo_path"`
-	Remote              string        `json:"remote"`
-	Branch              string        `json:"branch"`
-	RelativeContentPath string        `json:"relative_content_path"`
-	SSHAuth             SSHAuthConfig `json:"ssh_auth"`
+	Protocol            string         `json:"protocol"`
+	Host                string         `json:"host"`
+	RepoPath            string         `json:"repo_path"`
+	Remote              string         `json:"remote"`
+	Branch              string         `json:"branch"`
+	RelativeContentPath string         `json:"relative_content_path"`
+	SyncStrategy        string         `json:"sync_strategy"`
+	CloneDepth          int            `json:"clone_depth"`
+	SparseCheckout      bool           `json:"sparse_checkout"`
+	SSHAuth             SSHAuthConfig  `json:"ssh_auth"`
+	HTTPAuth            HTTPAuthConfig `json:"http_auth"`
+	TrustedPGPKeyRing   string         `json:"trusted_pgp_keyring"`
+	TrustedSSHKeys      []string       `json:"trusted_ssh_keys"`
 }
 
 type SSHAuthConfig struct {
@@ -30,9 +51,102 @@ type SSHAuthConfig struct {
 
 	// Password protecting the private key.
 	PrivateKeyPassword string `json:"private_key_password"`
+
+	// Absolute path to the known_hosts file used to verify the host key of the remote host.
+	KnownHostsPath string `json:"known_hosts_path"`
+
+	// Pinned SHA256 fingerprints of the host key of the remote host (e.g. "SHA256:+DiY3..."),
+	// any of which is accepted.
+	HostKeyFingerprints []string `json:"host_key_fingerprints"`
+}
+
+// Sync strategies. The pull strategy fails if the local branch diverges from the remote branch,
+// e.g. after a force-push, and the reset strategy discards local state and moves to the remote branch.
+const (
+	PullStrategy  = "pull"
+	ResetStrategy = "reset"
+)
+
+// HTTP authentication methods.
+const (
+	BasicAuthMethod = "basic"
+	TokenAuthMethod = "token"
+)
+
+type HTTPAuthConfig struct {
+	// Authentication method, either basic or token (bearer); empty if authentication is not required.
+	// Personal access tokens of hosting services which expect them as basic auth passwords (e.g. GitHub)
+	// should be configured with basic auth.
+	Method string `json:"method"`
+
+	// Username for basic auth.
+	User string `json:"user"`
+
+	// Password for basic auth, or token for token auth. Exactly one of Secret, SecretFile and SecretEnv
+	// must be set; secrets read from files and environment variables are read again on every sync.
+	Secret string `json:"secret"`
+
+	// Absolute path to the file which contains the secret.
+	SecretFile string `json:"secret_file"`
+
+	// Name of the environment variable which contains the secret.
+	SecretEnv string `json:"secret_env"`
+}
+
+// ReadSecret returns the configured secret from the configured source.
+func (c *HTTPAuthConfig) ReadSecret() (string, error) {
+	switch {
+	case c.SecretFile != "":
+		content, err := os.ReadFile(c.SecretFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %v", err)
+		}
+		return strings.TrimSpace(string(content)), nil
+	case c.SecretEnv != "":
+		secret, found := os.LookupEnv(c.SecretEnv)
+		if !found {
+			return "", fmt.Errorf("environment variable %s not set", c.SecretEnv)
+		}
+		return secret, nil
+	default:
+		return c.Secret, nil
+	}
+}
+
+func (c *HTTPAuthConfig) Validate() error {
+	switch c.Method {
+	case "":
+		return nil
+	case BasicAuthMethod:
+		if c.User == "" {
+			return ErrAuthParamMissing
+		}
+	case TokenAuthMethod:
+	default:
+		return ErrInvalidAuthMethod
+	}
+
+	sources := 0
+	for _, source := range []string{c.Secret, c.SecretFile, c.SecretEnv} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources == 0 {
+		return ErrAuthParamMissing
+	}
+	if sources > 1 {
+		return ErrAmbiguousSecret
+	}
+
+	return nil
 }
 
 func (c *Config) URL() string {
+	if c.Protocol == LocalProtocol {
+		return c.RepoPath
+	}
+
 	userPart := ""
 	if c.Protocol == SSHProtocol && c.SSHAuth.User != "" {
 		userPart = c.SSHAuth.User + "@"
@@ -48,7 +162,49 @@ func (c *Config) URL() string {
 }
 
 func (c *Config) Validate() error {
-	if c.Protocol != HTTPSProtocol && c.Protocol != SSHProtocol {
+	switch c.SyncStrategy {
+	case "":
+		c.SyncStrategy = PullStrategy
+	case PullStrategy, ResetStrategy:
+	default:
+		return ErrInvalidSyncStrategy
+	}
+
+	if c.CloneDepth < 0 {
+		return ErrInvalidCloneDepth
+	}
+
+	if c.SparseCheckout {
+		if c.SyncStrategy != ResetStrategy {
+			return ErrSparseCheckoutStrategy
+		}
+		if c.RelativeContentPath == "" || filepath.Clean(c.RelativeContentPath) == "." {
+			return ErrSparseCheckoutPath
+		}
+	}
+
+	if _, err := parseSSHSigningKeys(c.TrustedSSHKeys); err != nil {
+		return err
+	}
+
+	switch c.Protocol {
+	case HTTPSProtocol:
+		if err := c.HTTPAuth.Validate(); err != nil {
+			return err
+		}
+	case SSHProtocol:
+		if err := c.SSHAuth.validateHostKeyFingerprints(); err != nil {
+			return err
+		}
+	case FileProtocol:
+		if !filepath.IsAbs(c.RepoPath) {
+			return ErrRelativePath
+		}
+	case LocalProtocol:
+		if !filepath.IsAbs(strings.TrimPrefix(c.RepoPath, "file://")) {
+			return ErrRelativePath
+		}
+	default:
 		return ErrInvalidProtocol
 	}
 
@@ -0,0 +1,126 @@
+package repository_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/repository"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+func httpAuthMethod(t *testing.T, auth repository.HTTPAuthConfig) (transport.AuthMethod, error) {
+	t.Helper()
+
+	if err := auth.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	repo := &repository.GitRepository{Config: repository.Config{Protocol: repository.HTTPSProtocol, HTTPAuth: auth}}
+	return repo.HTTPAuthMethod()
+}
+
+func TestHTTPAuthMethods(t *testing.T) {
+	for _, test := range []struct {
+		auth     repository.HTTPAuthConfig
+		expected transport.AuthMethod
+	}{
+		{
+			repository.HTTPAuthConfig{},
+			nil,
+		},
+		{
+			repository.HTTPAuthConfig{Method: repository.BasicAuthMethod, User: "anduril", Secret: "password"},
+			&http.BasicAuth{Username: "anduril", Password: "password"},
+		},
+		{
+			repository.HTTPAuthConfig{Method: repository.TokenAuthMethod, Secret: "token"},
+			&http.TokenAuth{Token: "token"},
+		},
+	} {
+		method, err := httpAuthMethod(t, test.auth)
+		if err != nil {
+			t.Fatalf("%+v: %v", test.auth, err)
+		}
+		if !reflect.DeepEqual(method, test.expected) {
+			t.Fatalf("%+v: expected: %v found: %v", test.auth, test.expected, method)
+		}
+	}
+}
+
+func TestHTTPAuthSecretSources(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ANDURIL_TEST_TOKEN", "env-token")
+
+	for _, test := range []struct {
+		auth     repository.HTTPAuthConfig
+		expected string
+	}{
+		{repository.HTTPAuthConfig{Method: repository.TokenAuthMethod, SecretFile: secretFile}, "file-token"},
+		{repository.HTTPAuthConfig{Method: repository.TokenAuthMethod, SecretEnv: "ANDURIL_TEST_TOKEN"}, "env-token"},
+	} {
+		method, err := httpAuthMethod(t, test.auth)
+		if err != nil {
+			t.Fatalf("%+v: %v", test.auth, err)
+		}
+		if token := method.(*http.TokenAuth).Token; token != test.expected {
+			t.Fatalf("%+v: expected: %s found: %s", test.auth, test.expected, token)
+		}
+	}
+
+	// Secrets are read again every time, so that they can be rotated without a restart.
+	if err := os.WriteFile(secretFile, []byte("rotated-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ANDURIL_TEST_TOKEN", "rotated-env-token")
+	for _, test := range []struct {
+		auth     repository.HTTPAuthConfig
+		expected string
+	}{
+		{repository.HTTPAuthConfig{Method: repository.BasicAuthMethod, User: "anduril", SecretFile: secretFile}, "rotated-token"},
+		{repository.HTTPAuthConfig{Method: repository.BasicAuthMethod, User: "anduril", SecretEnv: "ANDURIL_TEST_TOKEN"}, "rotated-env-token"},
+	} {
+		method, err := httpAuthMethod(t, test.auth)
+		if err != nil {
+			t.Fatalf("%+v: %v", test.auth, err)
+		}
+		if password := method.(*http.BasicAuth).Password; password != test.expected {
+			t.Fatalf("%+v: expected: %s found: %s", test.auth, test.expected, password)
+		}
+	}
+
+	for _, auth := range []repository.HTTPAuthConfig{
+		{Method: repository.TokenAuthMethod, SecretFile: filepath.Join(t.TempDir(), "missing")},
+		{Method: repository.TokenAuthMethod, SecretEnv: "ANDURIL_TEST_MISSING_TOKEN"},
+	} {
+		if _, err := httpAuthMethod(t, auth); err == nil {
+			t.Fatalf("%+v: expected an error for a missing secret", auth)
+		}
+	}
+
+	t.Setenv("ANDURIL_TEST_TOKEN", "")
+	_, err := httpAuthMethod(t, repository.HTTPAuthConfig{Method: repository.TokenAuthMethod, SecretEnv: "ANDURIL_TEST_TOKEN"})
+	if err != repository.ErrAuthParamMissing {
+		t.Fatalf("empty secret: expected: %v found: %v", repository.ErrAuthParamMissing, err)
+	}
+}
+
+func TestHTTPAuthValidation(t *testing.T) {
+	for _, test := range []struct {
+		auth     repository.HTTPAuthConfig
+		expected error
+	}{
+		{repository.HTTPAuthConfig{Method: "digest", Secret: "password"}, repository.ErrInvalidAuthMethod},
+		{repository.HTTPAuthConfig{Method: repository.BasicAuthMethod, Secret: "password"}, repository.ErrAuthParamMissing},
+		{repository.HTTPAuthConfig{Method: repository.TokenAuthMethod}, repository.ErrAuthParamMissing},
+		{repository.HTTPAuthConfig{Method: repository.TokenAuthMethod, Secret: "token", SecretEnv: "TOKEN"}, repository.ErrAmbiguousSecret},
+	} {
+		if err := test.auth.Validate(); err != test.expected {
+			t.Fatalf("%+v: expected: %v found: %v", test.auth, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cicovic-andrija/anduril/service"
+)
+
+// DirectoryRepository is a repository served straight from a directory on the local file system,
+// without version control. Revisions are identified by a hash of the contents of the directory tree,
+// and new revisions are detected by hashing the tree again on every sync.
+type DirectoryRepository struct {
+	Config
+	revisionID  string
+	initialized bool
+	trace       service.TraceCallback
+}
+
+// Initialize opens the directory configured with RepoPath. The directory is never
+// copied, so the root argument is ignored.
+func (r *DirectoryRepository) Initialize(root string, trace service.TraceCallback) error {
+	r.trace = trace
+
+	info, err := os.Stat(r.RepoPath)
+	if err != nil {
+		return fmt.Errorf("open local directory %s: operation failed with error: %v", r.RepoPath, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("open local directory %s: not a directory", r.RepoPath)
+	}
+
+	revisionID, err := r.hashTree()
+	if err != nil {
+		return err
+	}
+
+	r.revisionID = revisionID
+	r.initialized = true
+	r.trace("opened local directory on path %s with content hash %s", r.RepoPath, r.revisionID)
+	return nil
+}
+
+func (r *DirectoryRepository) Sync() (bool, error) {
+	if r.Empty() {
+		return false, ErrNotInitialized
+	}
+
+	revisionID, err := r.hashTree()
+	if err != nil {
+		return false, err
+	}
+
+	if revisionID == r.revisionID {
+		r.trace("sync: content hash unchanged")
+		return false, nil
+	}
+
+	r.trace("sync: content hash changed from %s to %s", r.revisionID, revisionID)
+	r.revisionID = revisionID
+	return true, nil
+}
+
+func (r *DirectoryRepository) Root() string {
+	if r.Empty() {
+		return ""
+	}
+	return r.RepoPath
+}
+
+func (r *DirectoryRepository) ContentRoot() string {
+	if r.Empty() {
+		return ""
+	}
+	return filepath.Join(r.RepoPath, r.RelativeContentPath)
+}
+
+func (r *DirectoryRepository) LatestRevisionID() string {
+	if r.Empty() {
+		return ""
+	}
+	return r.revisionID[:10]
+}
+
+func (r *DirectoryRepository) Empty() bool {
+	return !r.initialized
+}
+
+// hashTree returns a hash of the names and contents of all files in the content directory tree.
+// Hidden files and directories (e.g. .git) are skipped.
+func (r *DirectoryRepository) hashTree() (string, error) {
+	contentRoot := filepath.Join(r.RepoPath, r.RelativeContentPath)
+	hash := sha256.New()
+
+	// Files are visited in lexical order, so the hash does not depend on the order
+	// in which the file system lists the directory entries.
+	err := filepath.WalkDir(contentRoot, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path != contentRoot && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(contentRoot, path)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		fileHash := sha256.New()
+		if _, err := io.Copy(fileHash, file); err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00%x\n", filepath.ToSlash(relativePath), fileHash.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash content directory %s: %v", contentRoot, err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,164 @@
+package repository_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cicovic-andrija/anduril/repository"
+)
+
+// writeFile writes the file to the directory, creating parent directories as needed.
+func writeFile(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newDirectoryRepository(t *testing.T, dir string) repository.Repository {
+	t.Helper()
+
+	repo, err := repository.New(repository.Config{
+		Protocol:            repository.FileProtocol,
+		RepoPath:            dir,
+		RelativeContentPath: "notes",
+	})
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	if err = repo.Initialize(t.TempDir(), t.Logf); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	return repo
+}
+
+func TestDirectoryRepositoryProtocol(t *testing.T) {
+	for _, test := range []struct {
+		protocol  string
+		directory bool
+	}{
+		{repository.FileProtocol, true},
+		{repository.LocalProtocol, false},
+	} {
+		repo, err := repository.New(repository.Config{Protocol: test.protocol, RepoPath: t.TempDir()})
+		if err != nil {
+			t.Fatalf("%s: new repository: %v", test.protocol, err)
+		}
+		if _, ok := repo.(*repository.DirectoryRepository); ok != test.directory {
+			t.Fatalf("%s: directory repository: expected: %v found: %v", test.protocol, test.directory, ok)
+		}
+	}
+
+	_, err := repository.New(repository.Config{Protocol: repository.FileProtocol, RepoPath: "notes"})
+	if err != repository.ErrRelativePath {
+		t.Fatalf("expected error %v, found: %v", repository.ErrRelativePath, err)
+	}
+}
+
+func TestDirectoryRepositoryInitialize(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "notes/first.md", "# First")
+
+	repo, err := repository.New(repository.Config{Protocol: repository.FileProtocol, RepoPath: dir, RelativeContentPath: "notes"})
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	if !repo.Empty() || repo.LatestRevisionID() != "" {
+		t.Fatalf("expected an empty repository before initialization")
+	}
+	if _, err = repo.Sync(); err != repository.ErrNotInitialized {
+		t.Fatalf("sync: expected error %v, found: %v", repository.ErrNotInitialized, err)
+	}
+
+	// The directory is served in place, and never copied.
+	if err = repo.Initialize(t.TempDir(), t.Logf); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if repo.Root() != dir || repo.ContentRoot() != filepath.Join(dir, "notes") {
+		t.Fatalf("root: expected: %s found: %s", dir, repo.Root())
+	}
+
+	missing := &repository.DirectoryRepository{Config: repository.Config{RepoPath: filepath.Join(dir, "missing")}}
+	if err = missing.Initialize(t.TempDir(), t.Logf); err == nil {
+		t.Fatalf("initialize: expected an error for a missing directory")
+	}
+}
+
+func TestDirectoryRepositoryRevisionHash(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	for _, dir := range []string{first, second} {
+		writeFile(t, dir, "notes/first.md", "# First")
+		writeFile(t, dir, "notes/nested/second.md", "# Second")
+	}
+	// Hidden files and files outside of the content directory are not hashed.
+	writeFile(t, second, "notes/.draft.md", "# Draft")
+	writeFile(t, second, "notes/.git/HEAD", "ref: refs/heads/master")
+	writeFile(t, second, "README.md", "# Notes")
+
+	revision := newDirectoryRepository(t, first).LatestRevisionID()
+	if len(revision) != 10 {
+		t.Fatalf("revision: expected a 10 character hash, found: %q", revision)
+	}
+	if other := newDirectoryRepository(t, second).LatestRevisionID(); other != revision {
+		t.Fatalf("revision: expected: %s found: %s", revision, other)
+	}
+
+	writeFile(t, second, "notes/nested/second.md", "# Second, edited")
+	if other := newDirectoryRepository(t, second).LatestRevisionID(); other == revision {
+		t.Fatalf("revision: expected a new hash for different content")
+	}
+}
+
+func TestDirectoryRepositorySync(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "notes/first.md", "# First")
+	repo := newDirectoryRepository(t, dir)
+
+	for _, test := range []struct {
+		name   string
+		change func()
+		found  bool
+	}{
+		{"unchanged", func() {}, false},
+		{"touched", func() {
+			now := time.Now().Add(time.Hour)
+			if err := os.Chtimes(filepath.Join(dir, "notes", "first.md"), now, now); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"hidden file", func() { writeFile(t, dir, "notes/.draft.md", "# Draft") }, false},
+		{"outside of content", func() { writeFile(t, dir, "README.md", "# Notes") }, false},
+		{"edited", func() { writeFile(t, dir, "notes/first.md", "# First, edited") }, true},
+		{"added", func() { writeFile(t, dir, "notes/second.md", "# Second") }, true},
+		{"renamed", func() {
+			if err := os.Rename(filepath.Join(dir, "notes", "second.md"), filepath.Join(dir, "notes", "third.md")); err != nil {
+				t.Fatal(err)
+			}
+		}, true},
+		{"removed", func() {
+			if err := os.Remove(filepath.Join(dir, "notes", "third.md")); err != nil {
+				t.Fatal(err)
+			}
+		}, true},
+	} {
+		previous := repo.LatestRevisionID()
+		test.change()
+		found, err := repo.Sync()
+		if err != nil {
+			t.Fatalf("%s: sync: %v", test.name, err)
+		}
+		if found != test.found {
+			t.Fatalf("%s: sync: expected: %v found: %v", test.name, test.found, found)
+		}
+		if changed := repo.LatestRevisionID() != previous; changed != test.found {
+			t.Fatalf("%s: revision changed: expected: %v found: %v", test.name, test.found, changed)
+		}
+	}
+}
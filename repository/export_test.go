@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"golang.org/x/crypto/ssh"
+)
+
+// Hooks into unexported routines of the package, used by tests.
+
+// HostKeyCallback returns the callback which verifies SSH host keys.
+func (c *SSHAuthConfig) HostKeyCallback() (ssh.HostKeyCallback, error) {
+	return c.hostKeyCallback()
+}
+
+// HTTPAuthMethod returns the method of HTTP authentication with the configured secret.
+func (r *GitRepository) HTTPAuthMethod() (transport.AuthMethod, error) {
+	return r.httpAuthMethod()
+}
+
+// AuthMethod returns the method of authentication with the remote.
+func (r *GitRepository) AuthMethod() (transport.AuthMethod, error) {
+	return r.authMethod()
+}
@@ -1,28 +1,48 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 
 	"github.com/cicovic-andrija/anduril/service"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 )
 
+// Suffixes of the paths next to the local repository where it is cloned again,
+// and where it is moved while it is replaced with the clone.
+const (
+	recloneSuffix  = ".clone"
+	previousSuffix = ".previous"
+)
+
 type GitRepository struct {
 	Config
-	repo    *git.Repository
-	root    string
-	tipHash string
-	trace   service.TraceCallback
+	repo     *git.Repository
+	root     string
+	tipHash  string
+	verifier *commitVerifier
+	trace    service.TraceCallback
 }
 
 func (r *GitRepository) Initialize(root string, trace service.TraceCallback) error {
 	r.root = root
 	r.trace = trace
+
+	verifier, err := r.newCommitVerifier()
+	if err != nil {
+		return err
+	}
+	r.verifier = verifier
+
 	return r.openOrClone()
 }
 
@@ -30,6 +50,10 @@ func (r *GitRepository) Sync() (bool, error) {
 	if r.Empty() {
 		return false, ErrNotInitialized
 	}
+	// Shallow repositories cannot be merged into, and are always reset.
+	if r.SyncStrategy == ResetStrategy || r.CloneDepth > 0 {
+		return r.syncWithReset()
+	}
 	return r.pull()
 }
 
@@ -59,44 +83,326 @@ func (r *GitRepository) Empty() bool {
 }
 
 // Try to open an existing local repository if there is one, otherwise clone it from remote location.
+// With the reset sync strategy, a local repository which cannot be opened, or is missing objects,
+// is cloned again.
 func (r *GitRepository) openOrClone() error {
 	local, err := git.PlainOpen(r.root)
 	if err != nil {
 		if err == git.ErrRepositoryNotExists {
 			r.trace("local repository not found on path %s", r.root)
-		} else {
+			return r.clone()
+		}
+		if r.SyncStrategy != ResetStrategy {
 			return fmt.Errorf("open local repository %s: operation failed with error: %v", r.root, err)
 		}
-	} else {
-		r.trace("opened local repository on path %s", r.root)
+		r.trace("open local repository %s: operation failed with error: %v", r.root, err)
+		return r.reclone()
+	}
+
+	r.trace("opened local repository on path %s", r.root)
+	if r.SyncStrategy != ResetStrategy {
+		if err := r.verifyHead(local); err != nil {
+			if !isRejectedCommit(err) {
+				return err
+			}
+			// The local commit could have been rejected before a trusted commit was pushed
+			// on top of it, so the latest changes are pulled and verified instead.
+			r.trace("%v: pulling latest changes", err)
+			if err := r.pullInto(local); err != nil {
+				return err
+			}
+			if err := r.verifyHead(local); err != nil {
+				return err
+			}
+		}
 		return r.validateRefs(local)
 	}
 
+	// The latest changes are fetched before the reset, because the commit of the remote-tracking
+	// branch could have been rejected. If they cannot be fetched, the local repository is reset to
+	// the commit fetched last.
+	r.repo = local
+	if err := r.fetch(); err != nil {
+		r.trace("%v", err)
+	}
+	if _, err := r.resetToRemote(); err != nil {
+		r.repo = nil
+		if isRejectedCommit(err) {
+			return err
+		}
+		r.trace("%v", err)
+		if isCorrupted(err) {
+			return r.reclone()
+		}
+		// The local repository is intact, and the commit checked out is served until the next sync.
+		if err := r.verifyHead(local); err != nil {
+			return err
+		}
+		return r.validateRefs(local)
+	}
+	return nil
+}
+
+func (r *GitRepository) clone() error {
+	clone, err := r.cloneInto(r.root)
+	if err != nil {
+		return err
+	}
+	if err := r.verifyHead(clone); err != nil {
+		return err
+	}
+	return r.validateRefs(clone)
+}
+
+// cloneInto clones the repository from the remote location to the local path.
+func (r *GitRepository) cloneInto(path string) (*git.Repository, error) {
 	auth, err := r.authMethod()
 	if err != nil {
-		return fmt.Errorf("clone repository: auth failed: %v", err)
+		return nil, fmt.Errorf("clone repository: auth failed: %v", err)
 	}
 
 	clone, err := git.PlainClone(
-		r.root,
+		path,
 		false, // bare
 		&git.CloneOptions{
 			URL:           r.URL(),
 			Auth:          auth,
 			RemoteName:    r.Remote,
 			ReferenceName: plumbing.NewBranchReferenceName(r.Branch),
+			Depth:         r.CloneDepth,
+			NoCheckout:    r.SparseCheckout,
 			Progress:      io.Discard,
 		},
 	)
 
 	if err != nil {
-		return fmt.Errorf("clone repository: remote %q: operation failed with error: %v", r.URL(), err)
+		return nil, fmt.Errorf("clone repository: remote %q: operation failed with error: %v", r.URL(), err)
+	}
+
+	if r.SparseCheckout {
+		head, err := clone.Head()
+		if err != nil {
+			return nil, fmt.Errorf("clone repository: failed to obtain a reference to the current commit: %v", err)
+		}
+		if err = r.checkoutSparsely(clone, path, head.Hash()); err != nil {
+			return nil, fmt.Errorf("clone repository: sparse checkout failed: %v", err)
+		}
+	}
+
+	r.trace(
+		"repository cloned from remote location %q to local path %s (depth: %d, sparse: %v)",
+		r.URL(),
+		path,
+		r.CloneDepth,
+		r.SparseCheckout,
+	)
+	return clone, nil
+}
+
+// reclone clones the repository again next to the local repository, and replaces the local
+// repository with the clone once it is cloned and verified. If cloning fails, the local repository
+// is left as it was.
+func (r *GitRepository) reclone() error {
+	r.trace("local repository on path %s is considered corrupted and will be cloned again", r.root)
+	cloneRoot, previousRoot := r.root+recloneSuffix, r.root+previousSuffix
+	for _, path := range []string{cloneRoot, previousRoot} {
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("delete %s: operation failed with error: %v", path, err)
+		}
+	}
+
+	clone, err := r.cloneInto(cloneRoot)
+	if err == nil {
+		err = r.verifyHead(clone)
+	}
+	if err != nil {
+		os.RemoveAll(cloneRoot)
+		return err
+	}
+
+	if err := os.Rename(r.root, previousRoot); err != nil && !os.IsNotExist(err) {
+		os.RemoveAll(cloneRoot)
+		return fmt.Errorf("move local repository %s: operation failed with error: %v", r.root, err)
+	}
+	if err := os.Rename(cloneRoot, r.root); err != nil {
+		os.Rename(previousRoot, r.root)
+		os.RemoveAll(cloneRoot)
+		return fmt.Errorf("move cloned repository to %s: operation failed with error: %v", r.root, err)
+	}
+	r.repo = nil
+	if err := os.RemoveAll(previousRoot); err != nil {
+		r.trace("delete %s: operation failed with error: %v", previousRoot, err)
 	}
 
-	r.trace("repository cloned from remote location %q to local path %s", r.URL(), r.root)
+	// The clone is opened again from its new location.
+	clone, err = git.PlainOpen(r.root)
+	if err != nil {
+		return fmt.Errorf("open local repository %s: operation failed with error: %v", r.root, err)
+	}
 	return r.validateRefs(clone)
 }
 
+// isCorrupted reports whether the error was caused by objects missing from the local repository,
+// which cannot be recovered without cloning the repository again.
+func isCorrupted(err error) bool {
+	return errors.Is(err, plumbing.ErrObjectNotFound)
+}
+
+// syncWithReset fetches the latest changes and moves the worktree to the tip of the remote branch,
+// discarding local changes and commits. Shallow repositories fetch only the latest CloneDepth
+// commits. If the local repository cannot be reset, it is cloned again.
+func (r *GitRepository) syncWithReset() (bool, error) {
+	if err := r.fetch(); err != nil {
+		return false, err
+	}
+
+	new, err := r.resetToRemote()
+	if err != nil {
+		if !isCorrupted(err) {
+			return false, err
+		}
+		r.trace("%v", err)
+		previousTipHash := r.tipHash
+		if err := r.reclone(); err != nil {
+			return false, err
+		}
+		return r.tipHash != previousTipHash, nil
+	}
+	return new, nil
+}
+
+// fetch fetches the latest changes of the remote branch into its remote-tracking branch.
+// Shallow repositories fetch only the latest CloneDepth commits.
+func (r *GitRepository) fetch() error {
+	auth, err := r.authMethod()
+	if err != nil {
+		return fmt.Errorf("fetch: auth failed: %v", err)
+	}
+
+	err = r.repo.Fetch(&git.FetchOptions{
+		RemoteName: r.Remote,
+		RefSpecs:   []config.RefSpec{r.remoteBranchRefSpec()},
+		Depth:      r.CloneDepth,
+		Auth:       auth,
+		Force:      true,
+		Progress:   io.Discard,
+	})
+	switch err {
+	case nil:
+		r.trace("fetch: successfully fetched latest changes")
+		return nil
+	case git.NoErrAlreadyUpToDate:
+		r.trace("fetch: already up-to-date")
+		return nil
+	default:
+		return fmt.Errorf("fetch operation failed: %v", err)
+	}
+}
+
+// resetToRemote moves the branch and the worktree to the commit of the remote-tracking branch,
+// unless they are already there.
+func (r *GitRepository) resetToRemote() (bool, error) {
+	branchRefName := plumbing.NewBranchReferenceName(r.Branch)
+	remoteRefName := plumbing.NewRemoteReferenceName(r.Remote, r.Branch)
+
+	remoteRef, err := r.repo.Reference(remoteRefName, true /* resolved */)
+	if err != nil {
+		return false, fmt.Errorf("reset: failed to obtain a reference to %q: %w", remoteRefName.Short(), err)
+	}
+	target := remoteRef.Hash()
+
+	previous := "unknown commit"
+	if head, err := r.repo.Head(); err == nil {
+		if head.Name() == branchRefName && head.Hash() == target && r.tipHash == target.String() {
+			r.trace("reset: already at the tip of %q", remoteRefName.Short())
+			return false, nil
+		}
+		previous = head.Hash().String()
+	}
+
+	if err := r.verifyCommit(r.repo, target); err != nil {
+		return false, err
+	}
+
+	w, err := r.repo.Worktree()
+	if err != nil {
+		return false, fmt.Errorf("reset: failed to obtain worktree: %w", err)
+	}
+	if err = r.repo.Storer.SetReference(plumbing.NewHashReference(branchRefName, target)); err != nil {
+		return false, fmt.Errorf("reset: failed to move branch %q: %w", r.Branch, err)
+	}
+	if err = r.repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branchRefName)); err != nil {
+		return false, fmt.Errorf("reset: failed to check out branch %q: %w", r.Branch, err)
+	}
+	if r.SparseCheckout {
+		if err = r.checkoutSparsely(r.repo, r.root, target); err != nil {
+			return false, fmt.Errorf("reset: sparse checkout failed: %w", err)
+		}
+	} else {
+		if err = w.Reset(&git.ResetOptions{Commit: target, Mode: git.HardReset}); err != nil {
+			return false, fmt.Errorf("reset: hard reset failed: %w", err)
+		}
+		if err = w.Clean(&git.CleanOptions{Dir: true}); err != nil {
+			return false, fmt.Errorf("reset: failed to remove untracked files: %w", err)
+		}
+	}
+
+	r.trace("reset: worktree moved from %s to %s at the tip of %q", previous, target, remoteRefName.Short())
+	return true, r.validateRefs(r.repo)
+}
+
+// checkoutSparsely replaces the content directory of the worktree in root with the content
+// subtree of the commit, and leaves the rest of the worktree empty. go-git's own sparse checkout
+// fails to update worktrees which do not contain the directories excluded from the checkout,
+// so the content files are written straight from the tree, and the index is not used.
+func (r *GitRepository) checkoutSparsely(repo *git.Repository, root string, commit plumbing.Hash) error {
+	contentRoot := filepath.Join(root, r.RelativeContentPath)
+	if err := os.RemoveAll(contentRoot); err != nil {
+		return err
+	}
+	return r.writeContentTree(repo, commit, contentRoot)
+}
+
+// writeContentTree writes the files of the content directory of the commit to dir.
+func (r *GitRepository) writeContentTree(repo *git.Repository, commit plumbing.Hash, dir string) error {
+	c, err := repo.CommitObject(commit)
+	if err != nil {
+		return fmt.Errorf("failed to obtain commit %s: %v", commit, err)
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return fmt.Errorf("failed to obtain tree of commit %s: %v", commit, err)
+	}
+	subtree, err := tree.Tree(filepath.ToSlash(filepath.Clean(r.RelativeContentPath)))
+	if err != nil {
+		return fmt.Errorf("failed to obtain content directory %q of commit %s: %v", r.RelativeContentPath, commit, err)
+	}
+
+	return subtree.Files().ForEach(func(f *object.File) error {
+		path := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		content, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		mode, err := f.Mode.ToOSFileMode()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, []byte(content), mode.Perm())
+	})
+}
+
+func (r *GitRepository) remoteBranchRefSpec() config.RefSpec {
+	return config.RefSpec(fmt.Sprintf(
+		"+%s:%s",
+		plumbing.NewBranchReferenceName(r.Branch),
+		plumbing.NewRemoteReferenceName(r.Remote, r.Branch),
+	))
+}
+
 func (r *GitRepository) pull() (new bool, err error) {
 	w, err := r.repo.Worktree()
 	if err != nil {
@@ -118,6 +424,10 @@ func (r *GitRepository) pull() (new bool, err error) {
 	switch err {
 	case nil:
 		r.trace("pull: successfully fetched and merged latest changes")
+		if err = r.verifyHead(r.repo); err != nil {
+			r.rollback(w)
+			return
+		}
 		new = true
 		err = r.validateRefs(r.repo)
 		return
@@ -133,6 +443,58 @@ func (r *GitRepository) pull() (new bool, err error) {
 	}
 }
 
+// pullInto fetches and merges the latest changes into the local repository, without
+// verifying them.
+func (r *GitRepository) pullInto(repo *git.Repository) error {
+	w, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("pull: failed to obtain worktree: %v", err)
+	}
+
+	auth, err := r.authMethod()
+	if err != nil {
+		return fmt.Errorf("pull: auth failed: %v", err)
+	}
+
+	switch err = w.Pull(&git.PullOptions{RemoteName: r.Remote, Auth: auth}); err {
+	case nil:
+		r.trace("pull: successfully fetched and merged latest changes")
+		return nil
+	case git.NoErrAlreadyUpToDate:
+		r.trace("pull: already up-to-date")
+		return nil
+	default:
+		return fmt.Errorf("pull operation failed: %v", err)
+	}
+}
+
+// rollback moves the branch and the worktree back to the last verified commit after a pull
+// brought in a commit which was rejected.
+func (r *GitRepository) rollback(w *git.Worktree) {
+	previous := plumbing.NewHash(r.tipHash)
+	err := r.repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(r.Branch), previous))
+	if err == nil {
+		err = w.Reset(&git.ResetOptions{Commit: previous, Mode: git.HardReset})
+	}
+	if err != nil {
+		r.trace("pull: failed to roll back to commit %s: %v", previous, err)
+		return
+	}
+	r.trace("pull: rolled back to commit %s", previous)
+}
+
+// verifyHead verifies the signature of the current commit of the repository.
+func (r *GitRepository) verifyHead(repo *git.Repository) error {
+	if r.verifier == nil {
+		return nil
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("failed to obtain a reference to the current commit: %v", err)
+	}
+	return r.verifyCommit(repo, head.Hash())
+}
+
 func (r *GitRepository) validateRefs(repo *git.Repository) error {
 	current, err := repo.Head()
 	if err != nil {
@@ -161,13 +523,50 @@ func (r *GitRepository) validateRefs(repo *git.Repository) error {
 }
 
 func (r *GitRepository) authMethod() (transport.AuthMethod, error) {
-	if r.Protocol != SSHProtocol {
+	switch r.Protocol {
+	case SSHProtocol:
+		auth, err := ssh.NewPublicKeysFromFile(
+			r.SSHAuth.User,
+			r.SSHAuth.PrivateKeyPath,
+			r.SSHAuth.PrivateKeyPassword,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if auth.HostKeyCallback, err = r.SSHAuth.hostKeyCallback(); err != nil {
+			return nil, err
+		}
+		hostWithPort, err := r.sshHostWithPort()
+		if err != nil {
+			return nil, err
+		}
+		algorithms, err := r.SSHAuth.hostKeyAlgorithms(hostWithPort)
+		if err != nil || len(algorithms) == 0 {
+			return auth, err
+		}
+		return &publicKeysAuth{PublicKeys: auth, hostKeyAlgorithms: algorithms}, nil
+	case HTTPSProtocol:
+		return r.httpAuthMethod()
+	default:
 		return nil, nil
 	}
+}
 
-	return ssh.NewPublicKeysFromFile(
-		r.SSHAuth.User,
-		r.SSHAuth.PrivateKeyPath,
-		r.SSHAuth.PrivateKeyPassword,
-	)
+func (r *GitRepository) httpAuthMethod() (transport.AuthMethod, error) {
+	if r.HTTPAuth.Method == "" {
+		return nil, nil
+	}
+
+	secret, err := r.HTTPAuth.ReadSecret()
+	if err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		return nil, ErrAuthParamMissing
+	}
+
+	if r.HTTPAuth.Method == TokenAuthMethod {
+		return &http.TokenAuth{Token: secret}, nil
+	}
+	return &http.BasicAuth{Username: r.HTTPAuth.User, Password: secret}, nil
 }
@@ -0,0 +1,671 @@
+package repository_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cicovic-andrija/anduril/repository"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+)
+
+// testRemote is a bare repository on the local file system, updated from a separate working repository.
+type testRemote struct {
+	t       *testing.T
+	work    *git.Repository
+	workDir string
+	bareDir string
+}
+
+func newTestRemote(t *testing.T) *testRemote {
+	// The file transport runs git-upload-pack and git-receive-pack.
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	remote := &testRemote{
+		t:       t,
+		workDir: filepath.Join(dir, "work"),
+		bareDir: filepath.Join(dir, "notes.git"),
+	}
+
+	var err error
+	if remote.work, err = git.PlainInit(remote.workDir, false /* bare */); err != nil {
+		t.Fatalf("init working repository: %v", err)
+	}
+	remote.commit("notes/first.md", "# First")
+
+	if _, err = git.PlainInit(remote.bareDir, true /* bare */); err != nil {
+		t.Fatalf("init bare repository: %v", err)
+	}
+	if _, err = remote.work.CreateRemote(&config.RemoteConfig{Name: "bare", URLs: []string{remote.bareDir}}); err != nil {
+		t.Fatalf("create remote: %v", err)
+	}
+	remote.push()
+
+	return remote
+}
+
+func (r *testRemote) commit(name string, content string) plumbing.Hash {
+	path := filepath.Join(r.workDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+
+	w, err := r.work.Worktree()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if _, err = w.Add(name); err != nil {
+		r.t.Fatalf("add %s: %v", name, err)
+	}
+	hash, err := w.Commit("Update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		r.t.Fatalf("commit %s: %v", name, err)
+	}
+	return hash
+}
+
+func (r *testRemote) push() {
+	err := r.work.Push(&git.PushOptions{
+		RemoteName: "bare",
+		RefSpecs:   []config.RefSpec{"+refs/heads/master:refs/heads/master"},
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		r.t.Fatalf("push: %v", err)
+	}
+}
+
+// rewind moves the branch of the working repository back to the commit.
+func (r *testRemote) rewind(commit plumbing.Hash) {
+	w, err := r.work.Worktree()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if err = w.Reset(&git.ResetOptions{Commit: commit, Mode: git.HardReset}); err != nil {
+		r.t.Fatalf("reset: %v", err)
+	}
+}
+
+// sign replaces the commit at the tip of the branch with a copy signed with the SSH key, as git does
+// with gpg.format set to ssh, and returns the hash of the signed commit.
+func (r *testRemote) sign(signer ssh.Signer) plumbing.Hash {
+	commit, err := r.work.CommitObject(r.head())
+	if err != nil {
+		r.t.Fatal(err)
+	}
+
+	payload := &plumbing.MemoryObject{}
+	if err = commit.EncodeWithoutSignature(payload); err != nil {
+		r.t.Fatal(err)
+	}
+	reader, err := payload.Reader()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	message, err := io.ReadAll(reader)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+
+	digest := sha512.Sum512(message)
+	signedData := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{"git", "", "sha512", digest[:]})...)
+	signature, err := signer.Sign(rand.Reader, signedData)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	blob := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}{1, signer.PublicKey().Marshal(), "git", "", "sha512", ssh.Marshal(signature)})...)
+	commit.PGPSignature = "-----BEGIN SSH SIGNATURE-----\n" +
+		base64.StdEncoding.EncodeToString(blob) +
+		"\n-----END SSH SIGNATURE-----\n"
+
+	encoded := r.work.Storer.NewEncodedObject()
+	if err = commit.Encode(encoded); err != nil {
+		r.t.Fatal(err)
+	}
+	hash, err := r.work.Storer.SetEncodedObject(encoded)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	r.rewind(hash)
+	return hash
+}
+
+func (r *testRemote) head() plumbing.Hash {
+	head, err := r.work.Head()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return head.Hash()
+}
+
+func newLocalRepository(t *testing.T, repoPath string) repository.Repository {
+	return newLocalRepositoryWithStrategy(t, repoPath, repository.PullStrategy)
+}
+
+func newLocalRepositoryWithStrategy(t *testing.T, repoPath string, strategy string) repository.Repository {
+	repo, err := repository.New(repository.Config{
+		Protocol:            repository.LocalProtocol,
+		RepoPath:            repoPath,
+		Remote:              "origin",
+		Branch:              "master",
+		RelativeContentPath: "notes",
+		SyncStrategy:        strategy,
+	})
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	if err = repo.Initialize(filepath.Join(t.TempDir(), "repo"), t.Logf); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	return repo
+}
+
+func TestLocalRepositoryFileURL(t *testing.T) {
+	remote := newTestRemote(t)
+	repo := newLocalRepository(t, "file://"+remote.bareDir)
+
+	if repo.LatestRevisionID() != remote.head().String()[:10] {
+		t.Fatalf("revision: expected: %s found: %s", remote.head().String()[:10], repo.LatestRevisionID())
+	}
+	if _, err := os.Stat(filepath.Join(repo.ContentRoot(), "first.md")); err != nil {
+		t.Fatalf("content: %v", err)
+	}
+
+	found, err := repo.Sync()
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if found {
+		t.Fatalf("sync: expected no new revision")
+	}
+
+	remote.commit("notes/second.md", "# Second")
+	remote.push()
+
+	found, err = repo.Sync()
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if !found {
+		t.Fatalf("sync: expected a new revision")
+	}
+	if repo.LatestRevisionID() != remote.head().String()[:10] {
+		t.Fatalf("revision: expected: %s found: %s", remote.head().String()[:10], repo.LatestRevisionID())
+	}
+	if _, err := os.Stat(filepath.Join(repo.ContentRoot(), "second.md")); err != nil {
+		t.Fatalf("content: %v", err)
+	}
+}
+
+func TestLocalRepositoryPlainPath(t *testing.T) {
+	remote := newTestRemote(t)
+	repo := newLocalRepository(t, remote.bareDir)
+
+	if repo.LatestRevisionID() != remote.head().String()[:10] {
+		t.Fatalf("revision: expected: %s found: %s", remote.head().String()[:10], repo.LatestRevisionID())
+	}
+}
+
+func TestResetStrategyFollowsForcePush(t *testing.T) {
+	remote := newTestRemote(t)
+	first := remote.head()
+	remote.commit("notes/second.md", "# Second")
+	remote.push()
+
+	repo := newLocalRepositoryWithStrategy(t, remote.bareDir, repository.ResetStrategy)
+
+	// Local changes must not survive the reset.
+	untracked := filepath.Join(repo.ContentRoot(), "untracked.md")
+	if err := os.WriteFile(untracked, []byte("# Untracked"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	remote.rewind(first)
+	remote.commit("notes/third.md", "# Third")
+	remote.push()
+
+	found, err := repo.Sync()
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if !found {
+		t.Fatalf("sync: expected a new revision")
+	}
+	if repo.LatestRevisionID() != remote.head().String()[:10] {
+		t.Fatalf("revision: expected: %s found: %s", remote.head().String()[:10], repo.LatestRevisionID())
+	}
+	for name, expected := range map[string]bool{"first.md": true, "second.md": false, "third.md": true, "untracked.md": false} {
+		if _, err := os.Stat(filepath.Join(repo.ContentRoot(), name)); (err == nil) != expected {
+			t.Fatalf("content: %s: expected to exist: %v", name, expected)
+		}
+	}
+
+	found, err = repo.Sync()
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if found {
+		t.Fatalf("sync: expected no new revision")
+	}
+}
+
+func TestResetStrategyReclonesCorruptedRepository(t *testing.T) {
+	remote := newTestRemote(t)
+	repo := newLocalRepositoryWithStrategy(t, remote.bareDir, repository.ResetStrategy)
+
+	// Corrupt the local repository, which is then opened by the server on the next start.
+	if err := os.RemoveAll(filepath.Join(repo.Root(), ".git", "objects")); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := repository.New(repository.Config{
+		Protocol:            repository.LocalProtocol,
+		RepoPath:            remote.bareDir,
+		Remote:              "origin",
+		Branch:              "master",
+		RelativeContentPath: "notes",
+		SyncStrategy:        repository.ResetStrategy,
+	})
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	if err = reopened.Initialize(repo.Root(), t.Logf); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if reopened.LatestRevisionID() != remote.head().String()[:10] {
+		t.Fatalf("revision: expected: %s found: %s", remote.head().String()[:10], reopened.LatestRevisionID())
+	}
+}
+
+func TestResetStrategyKeepsRepositoryOffline(t *testing.T) {
+	remote := newTestRemote(t)
+	repo := newLocalRepositoryWithStrategy(t, remote.bareDir, repository.ResetStrategy)
+	revision := repo.LatestRevisionID()
+
+	// The remote is unreachable, and the local repository cannot be reset to the remote-tracking
+	// branch, but is not corrupted, so it is served as it is.
+	if err := os.Rename(remote.bareDir, remote.bareDir+".offline"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(repo.Root(), ".git", "refs", "remotes", "origin", "master")); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := repository.New(repository.Config{
+		Protocol:            repository.LocalProtocol,
+		RepoPath:            remote.bareDir,
+		Remote:              "origin",
+		Branch:              "master",
+		RelativeContentPath: "notes",
+		SyncStrategy:        repository.ResetStrategy,
+	})
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	if err = reopened.Initialize(repo.Root(), t.Logf); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if reopened.LatestRevisionID() != revision {
+		t.Fatalf("revision: expected: %s found: %s", revision, reopened.LatestRevisionID())
+	}
+	if _, err := os.Stat(filepath.Join(reopened.ContentRoot(), "first.md")); err != nil {
+		t.Fatalf("content: %v", err)
+	}
+	if _, err = reopened.Sync(); err == nil {
+		t.Fatalf("sync: expected an error for an unreachable remote")
+	}
+	if reopened.LatestRevisionID() != revision {
+		t.Fatalf("revision after failed sync: expected: %s found: %s", revision, reopened.LatestRevisionID())
+	}
+}
+
+func TestResetStrategyKeepsCorruptedRepositoryIfRecloneFails(t *testing.T) {
+	remote := newTestRemote(t)
+	repo := newLocalRepositoryWithStrategy(t, remote.bareDir, repository.ResetStrategy)
+	content := filepath.Join(repo.ContentRoot(), "first.md")
+
+	if err := os.RemoveAll(filepath.Join(repo.Root(), ".git", "objects")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(remote.bareDir, remote.bareDir+".offline"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := repository.New(repository.Config{
+		Protocol:            repository.LocalProtocol,
+		RepoPath:            remote.bareDir,
+		Remote:              "origin",
+		Branch:              "master",
+		RelativeContentPath: "notes",
+		SyncStrategy:        repository.ResetStrategy,
+	})
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	if err = reopened.Initialize(repo.Root(), t.Logf); err == nil {
+		t.Fatalf("initialize: expected an error for an unreachable remote")
+	}
+
+	// The local repository is replaced only by a successful clone.
+	if _, err := os.Stat(content); err != nil {
+		t.Fatalf("content: %v", err)
+	}
+	if _, err := os.Stat(repo.Root() + ".clone"); !os.IsNotExist(err) {
+		t.Fatalf("clone: expected the failed clone to be removed, found: %v", err)
+	}
+
+	// Once the remote is reachable again, the repository is cloned again.
+	if err := os.Rename(remote.bareDir+".offline", remote.bareDir); err != nil {
+		t.Fatal(err)
+	}
+	if err = reopened.Initialize(repo.Root(), t.Logf); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if reopened.LatestRevisionID() != remote.head().String()[:10] {
+		t.Fatalf("revision: expected: %s found: %s", remote.head().String()[:10], reopened.LatestRevisionID())
+	}
+}
+
+func TestSparseCheckout(t *testing.T) {
+	for _, depth := range []int{0, 1} {
+		remote := newTestRemote(t)
+		remote.commit("drafts/draft.md", "# Draft")
+		remote.commit("notes/second.md", "# Second")
+		remote.push()
+
+		repo, err := repository.New(repository.Config{
+			Protocol:            repository.LocalProtocol,
+			RepoPath:            "file://" + remote.bareDir,
+			Remote:              "origin",
+			Branch:              "master",
+			RelativeContentPath: "notes",
+			SyncStrategy:        repository.ResetStrategy,
+			CloneDepth:          depth,
+			SparseCheckout:      true,
+		})
+		if err != nil {
+			t.Fatalf("depth %d: new repository: %v", depth, err)
+		}
+		if err = repo.Initialize(filepath.Join(t.TempDir(), "repo"), t.Logf); err != nil {
+			t.Fatalf("depth %d: initialize: %v", depth, err)
+		}
+
+		_, err = os.Stat(filepath.Join(repo.Root(), ".git", "shallow"))
+		if (err == nil) != (depth > 0) {
+			t.Fatalf("depth %d: expected a shallow clone: %v", depth, depth > 0)
+		}
+
+		// The local repository is updated in place.
+		marker := filepath.Join(repo.Root(), ".git", "marker")
+		if err = os.WriteFile(marker, nil, 0644); err != nil {
+			t.Fatalf("depth %d: marker: %v", depth, err)
+		}
+
+		remote.commit("notes/third.md", "# Third")
+		remote.commit("drafts/another.md", "# Another")
+		remote.push()
+
+		found, err := repo.Sync()
+		if err != nil {
+			t.Fatalf("depth %d: sync: %v", depth, err)
+		}
+		if !found {
+			t.Fatalf("depth %d: sync: expected a new revision", depth)
+		}
+		if repo.LatestRevisionID() != remote.head().String()[:10] {
+			t.Fatalf("depth %d: revision: expected: %s found: %s", depth, remote.head().String()[:10], repo.LatestRevisionID())
+		}
+		if _, err = os.Stat(marker); err != nil {
+			t.Fatalf("depth %d: expected the local repository to be synced in place: %v", depth, err)
+		}
+		for name, expected := range map[string]bool{"notes/first.md": true, "notes/third.md": true, "drafts": false} {
+			if _, err := os.Stat(filepath.Join(repo.Root(), filepath.FromSlash(name))); (err == nil) != expected {
+				t.Fatalf("depth %d: content: %s: expected to exist: %v", depth, name, expected)
+			}
+		}
+	}
+}
+
+func TestSparseCheckoutRequiresResetStrategy(t *testing.T) {
+	_, err := repository.New(repository.Config{
+		Protocol:            repository.LocalProtocol,
+		RepoPath:            "/srv/notes.git",
+		RelativeContentPath: "notes",
+		SparseCheckout:      true,
+	})
+	if err != repository.ErrSparseCheckoutStrategy {
+		t.Fatalf("expected error %v, found: %v", repository.ErrSparseCheckoutStrategy, err)
+	}
+}
+
+func TestLocalRepositoryRelativePath(t *testing.T) {
+	_, err := repository.New(repository.Config{
+		Protocol: repository.LocalProtocol,
+		RepoPath: "notes.git",
+	})
+	if err != repository.ErrRelativePath {
+		t.Fatalf("expected error %v, found: %v", repository.ErrRelativePath, err)
+	}
+}
+
+func TestSignedCommits(t *testing.T) {
+	_, trustedKey, _ := ed25519.GenerateKey(rand.Reader)
+	trusted, err := ssh.NewSignerFromKey(trustedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, untrustedKey, _ := ed25519.GenerateKey(rand.Reader)
+	untrusted, err := ssh.NewSignerFromKey(untrustedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, strategy := range []string{repository.PullStrategy, repository.ResetStrategy} {
+		remote := newTestRemote(t)
+		remote.sign(trusted)
+		remote.push()
+
+		repo, err := repository.New(repository.Config{
+			Protocol:            repository.LocalProtocol,
+			RepoPath:            remote.bareDir,
+			Remote:              "origin",
+			Branch:              "master",
+			RelativeContentPath: "notes",
+			SyncStrategy:        strategy,
+			TrustedSSHKeys:      []string{string(ssh.MarshalAuthorizedKey(trusted.PublicKey()))},
+		})
+		if err != nil {
+			t.Fatalf("%s: new repository: %v", strategy, err)
+		}
+		if err = repo.Initialize(filepath.Join(t.TempDir(), "repo"), t.Logf); err != nil {
+			t.Fatalf("%s: initialize: %v", strategy, err)
+		}
+		verified := repo.LatestRevisionID()
+
+		// Neither unsigned commits nor commits signed with untrusted keys are published,
+		// and the last verified revision is kept.
+		remote.commit("notes/unsigned.md", "# Unsigned")
+		remote.push()
+		if _, err = repo.Sync(); err == nil {
+			t.Fatalf("%s: sync: expected an unsigned commit to be rejected", strategy)
+		}
+		remote.commit("notes/untrusted.md", "# Untrusted")
+		rejected := remote.sign(untrusted)
+		remote.push()
+		if _, err = repo.Sync(); err == nil {
+			t.Fatalf("%s: sync: expected a commit signed with an untrusted key to be rejected", strategy)
+		}
+
+		// Rejected commits were fetched, but are not in the history of the published commit.
+		_, err = repo.(repository.History).ExportRevision(rejected.String(), t.TempDir())
+		if err != repository.ErrRevisionNotFound {
+			t.Fatalf("%s: export: expected error %v, found: %v", strategy, repository.ErrRevisionNotFound, err)
+		}
+		if repo.LatestRevisionID() != verified {
+			t.Fatalf("%s: revision: expected: %s found: %s", strategy, verified, repo.LatestRevisionID())
+		}
+		if _, err := os.Stat(filepath.Join(repo.ContentRoot(), "untrusted.md")); err == nil {
+			t.Fatalf("%s: content: untrusted.md published", strategy)
+		}
+
+		remote.commit("notes/trusted.md", "# Trusted")
+		remote.sign(trusted)
+		remote.push()
+		found, err := repo.Sync()
+		if err != nil {
+			t.Fatalf("%s: sync: %v", strategy, err)
+		}
+		if !found {
+			t.Fatalf("%s: sync: expected a new revision", strategy)
+		}
+		if repo.LatestRevisionID() != remote.head().String()[:10] {
+			t.Fatalf("%s: revision: expected: %s found: %s", strategy, remote.head().String()[:10], repo.LatestRevisionID())
+		}
+	}
+}
+
+func TestSignedCommitsRecoverFromRejectedClone(t *testing.T) {
+	_, trustedKey, _ := ed25519.GenerateKey(rand.Reader)
+	trusted, err := ssh.NewSignerFromKey(trustedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, strategy := range []string{repository.PullStrategy, repository.ResetStrategy} {
+		// The tip of the remote is unsigned when the repository is first cloned.
+		remote := newTestRemote(t)
+		repo, err := repository.New(repository.Config{
+			Protocol:            repository.LocalProtocol,
+			RepoPath:            remote.bareDir,
+			Remote:              "origin",
+			Branch:              "master",
+			RelativeContentPath: "notes",
+			SyncStrategy:        strategy,
+			TrustedSSHKeys:      []string{string(ssh.MarshalAuthorizedKey(trusted.PublicKey()))},
+		})
+		if err != nil {
+			t.Fatalf("%s: new repository: %v", strategy, err)
+		}
+		root := filepath.Join(t.TempDir(), "repo")
+		if err = repo.Initialize(root, t.Logf); err == nil {
+			t.Fatalf("%s: initialize: expected an unsigned commit to be rejected", strategy)
+		}
+		if !repo.Empty() {
+			t.Fatalf("%s: expected an empty repository after the rejected clone", strategy)
+		}
+
+		// Once a trusted commit is pushed, the local clone is initialized with it.
+		remote.commit("notes/trusted.md", "# Trusted")
+		remote.sign(trusted)
+		remote.push()
+		if err = repo.Initialize(root, t.Logf); err != nil {
+			t.Fatalf("%s: initialize: %v", strategy, err)
+		}
+		if repo.LatestRevisionID() != remote.head().String()[:10] {
+			t.Fatalf("%s: revision: expected: %s found: %s", strategy, remote.head().String()[:10], repo.LatestRevisionID())
+		}
+		if _, err := os.Stat(filepath.Join(repo.ContentRoot(), "trusted.md")); err != nil {
+			t.Fatalf("%s: content: %v", strategy, err)
+		}
+		if _, err = repo.Sync(); err != nil {
+			t.Fatalf("%s: sync: %v", strategy, err)
+		}
+	}
+}
+
+func TestExportRevision(t *testing.T) {
+	remote := newTestRemote(t)
+	first := remote.head()
+	remote.commit("notes/second.md", "# Second")
+	remote.push()
+
+	repo := newLocalRepository(t, remote.bareDir)
+	history, ok := repo.(repository.History)
+	if !ok {
+		t.Fatalf("git repository does not implement history")
+	}
+
+	dir := t.TempDir()
+	id, err := history.ExportRevision(first.String()[:7], dir)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if id != first.String() {
+		t.Fatalf("export: expected: %s found: %s", first, id)
+	}
+	for name, expected := range map[string]bool{"first.md": true, "second.md": false} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != expected {
+			t.Fatalf("content: %s: expected to exist: %v", name, expected)
+		}
+	}
+
+	// Commits which were not synced are not exported.
+	remote.commit("notes/third.md", "# Third")
+	remote.push()
+	if _, err = history.ExportRevision(remote.head().String(), t.TempDir()); err != repository.ErrRevisionNotFound {
+		t.Fatalf("export: expected error %v, found: %v", repository.ErrRevisionNotFound, err)
+	}
+}
+
+func TestFileHistory(t *testing.T) {
+	remote := newTestRemote(t)
+	first := remote.head()
+	remote.commit("notes/second.md", "# Second")
+	edited := remote.commit("notes/first.md", "# First, edited")
+	remote.push()
+
+	repo := newLocalRepository(t, remote.bareDir)
+	history := repo.(repository.History)
+
+	revisions, err := history.FileHistory("first.md", 0)
+	if err != nil {
+		t.Fatalf("file history: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].ID != edited.String() || revisions[1].ID != first.String() {
+		t.Fatalf("file history: expected: [%s %s] found: %+v", edited, first, revisions)
+	}
+	if revisions, _ = history.FileHistory("first.md", 1); len(revisions) != 1 {
+		t.Fatalf("file history: expected 1 revision, found: %d", len(revisions))
+	}
+
+	content, err := history.FileContent(first.String()[:7], "first.md")
+	if err != nil {
+		t.Fatalf("file content: %v", err)
+	}
+	if string(content) != "# First" {
+		t.Fatalf("file content: expected: %q found: %q", "# First", content)
+	}
+	if _, err = history.FileContent(first.String(), "second.md"); err != repository.ErrFileNotFound {
+		t.Fatalf("file content: expected error %v, found: %v", repository.ErrFileNotFound, err)
+	}
+}
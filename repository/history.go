@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// ExportRevision writes the content files of the commit identified by its hash or a prefix of it.
+// Only commits in the history of the current commit can be exported, so that commits which were
+// fetched but never published (e.g. rejected for a missing signature) are not exposed.
+// Shallow repositories can only export commits within the cloned depth.
+func (r *GitRepository) ExportRevision(id string, dir string) (string, error) {
+	commit, err := r.publishedCommit(id)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.writeContentTree(r.repo, commit.Hash, dir); err != nil {
+		return "", fmt.Errorf("export revision %s: %v", id, err)
+	}
+	r.trace("exported content of commit %s to %s", commit.Hash, dir)
+	return commit.Hash.String(), nil
+}
+
+// FileHistory returns the commits which changed the content file, newest first.
+// Renames are not followed, and history of shallow repositories ends at the cloned depth.
+func (r *GitRepository) FileHistory(name string, limit int) ([]FileRevision, error) {
+	if r.Empty() {
+		return nil, ErrNotInitialized
+	}
+
+	filePath := r.contentFilePath(name)
+	commits, err := r.repo.Log(&git.LogOptions{
+		From:     plumbing.NewHash(r.tipHash),
+		FileName: &filePath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("history of %s: %v", filePath, err)
+	}
+	defer commits.Close()
+
+	revisions := []FileRevision{}
+	err = commits.ForEach(func(c *object.Commit) error {
+		revisions = append(revisions, FileRevision{
+			ID:      c.Hash.String(),
+			Author:  c.Author.Name,
+			Time:    c.Committer.When,
+			Message: strings.TrimSpace(c.Message),
+		})
+		if len(revisions) == limit {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil && !(errors.Is(err, plumbing.ErrObjectNotFound) && len(revisions) > 0) {
+		return nil, fmt.Errorf("history of %s: %v", filePath, err)
+	}
+	return revisions, nil
+}
+
+// FileContent returns the content of the content file in the commit identified by its hash or a prefix of it.
+func (r *GitRepository) FileContent(id string, name string) ([]byte, error) {
+	commit, err := r.publishedCommit(id)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := commit.File(r.contentFilePath(name))
+	if err == object.ErrFileNotFound {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("content of %s in revision %s: %v", name, id, err)
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("content of %s in revision %s: %v", name, id, err)
+	}
+	return []byte(content), nil
+}
+
+// publishedCommit resolves the commit identified by its hash or a prefix of it, and checks that
+// it is in the history of the current commit.
+func (r *GitRepository) publishedCommit(id string) (*object.Commit, error) {
+	if r.Empty() {
+		return nil, ErrNotInitialized
+	}
+
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(id))
+	if err != nil {
+		return nil, ErrRevisionNotFound
+	}
+	commit, err := r.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, ErrRevisionNotFound
+	}
+
+	if commit.Hash.String() != r.tipHash {
+		tip, err := r.repo.CommitObject(plumbing.NewHash(r.tipHash))
+		if err != nil {
+			return nil, fmt.Errorf("revision %s: failed to obtain current commit: %v", id, err)
+		}
+		published, err := commit.IsAncestor(tip)
+		if err != nil {
+			return nil, fmt.Errorf("revision %s: failed to walk history: %v", id, err)
+		}
+		if !published {
+			return nil, ErrRevisionNotFound
+		}
+	}
+
+	return commit, nil
+}
+
+// contentFilePath returns the path of the content file relative to the root of the repository,
+// in the format used by git.
+func (r *GitRepository) contentFilePath(name string) string {
+	return path.Join(filepath.ToSlash(filepath.Clean(r.RelativeContentPath)), name)
+}
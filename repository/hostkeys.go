@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/skeema/knownhosts"
+	"golang.org/x/crypto/ssh"
+	xknownhosts "golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Prefix of SSH host key fingerprints, in the format printed by ssh-keygen -l.
+const FingerprintPrefix = "SHA256:"
+
+// hostKeyCallback returns a callback which verifies the SSH host key against the configured
+// known_hosts file and pinned fingerprints. When both are configured, the key must pass both checks.
+// If neither is configured, nil is returned, and the default known_hosts files are used.
+func (c *SSHAuthConfig) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if c.KnownHostsPath == "" && len(c.HostKeyFingerprints) == 0 {
+		return nil, nil
+	}
+
+	var knownHostsCallback ssh.HostKeyCallback
+	if c.KnownHostsPath != "" {
+		callback, err := c.knownHosts()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsCallback = callback.HostKeyCallback()
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(key)
+
+		if knownHostsCallback != nil {
+			if err := knownHostsCallback(hostname, remote, key); err != nil {
+				var keyErr *xknownhosts.KeyError
+				if errors.As(err, &keyErr) && len(keyErr.Want) > 0 {
+					return fmt.Errorf(
+						"host key mismatch for %s: presented key %s does not match the key in %s:%d",
+						hostname,
+						fingerprint,
+						keyErr.Want[0].Filename,
+						keyErr.Want[0].Line,
+					)
+				}
+				if errors.As(err, &keyErr) {
+					return fmt.Errorf("host key verification failed for %s: host not found in %s", hostname, c.KnownHostsPath)
+				}
+				return fmt.Errorf("host key verification failed for %s: %v", hostname, err)
+			}
+		}
+
+		if len(c.HostKeyFingerprints) > 0 {
+			for _, pinned := range c.HostKeyFingerprints {
+				if pinned == fingerprint {
+					return nil
+				}
+			}
+			return fmt.Errorf(
+				"host key mismatch for %s: presented key %s does not match any of the pinned fingerprints",
+				hostname,
+				fingerprint,
+			)
+		}
+
+		return nil
+	}, nil
+}
+
+// hostKeyAlgorithms returns the types of the keys of the host in the configured known_hosts file,
+// in the order of preference, or nil if the known_hosts file is not configured. Servers otherwise
+// negotiate the key type they prefer, which fails verification if the host is known by another type.
+func (c *SSHAuthConfig) hostKeyAlgorithms(hostWithPort string) ([]string, error) {
+	if c.KnownHostsPath == "" {
+		return nil, nil
+	}
+	callback, err := c.knownHosts()
+	if err != nil {
+		return nil, err
+	}
+	return callback.HostKeyAlgorithms(hostWithPort), nil
+}
+
+func (c *SSHAuthConfig) knownHosts() (knownhosts.HostKeyCallback, error) {
+	callback, err := knownhosts.New(c.KnownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts file %s: %v", c.KnownHostsPath, err)
+	}
+	return callback, nil
+}
+
+// sshHostWithPort returns the host and port of the remote, as they are looked up in known_hosts files.
+func (c *Config) sshHostWithPort() (string, error) {
+	endpoint, err := transport.NewEndpoint(c.URL())
+	if err != nil {
+		return "", err
+	}
+	port := endpoint.Port
+	if port <= 0 {
+		port = 22
+	}
+	return net.JoinHostPort(endpoint.Host, strconv.Itoa(port)), nil
+}
+
+// publicKeysAuth is the SSH public key auth method, which offers the server only the host key
+// algorithms of the known host. go-git looks them up only when it verifies host keys itself.
+type publicKeysAuth struct {
+	*gitssh.PublicKeys
+	hostKeyAlgorithms []string
+}
+
+func (a *publicKeysAuth) ClientConfig() (*ssh.ClientConfig, error) {
+	config, err := a.PublicKeys.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.HostKeyAlgorithms = a.hostKeyAlgorithms
+	return config, nil
+}
+
+func (c *SSHAuthConfig) validateHostKeyFingerprints() error {
+	for _, fingerprint := range c.HostKeyFingerprints {
+		if !strings.HasPrefix(fingerprint, FingerprintPrefix) {
+			return fmt.Errorf("invalid host key fingerprint %q: must start with %q", fingerprint, FingerprintPrefix)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package repository_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cicovic-andrija/anduril/repository"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const testHost = "git.example.com:22"
+
+var testHostAddr = &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// writeKnownHosts writes a known_hosts file in which the host has the key.
+func writeKnownHosts(t *testing.T, host string, key ssh.PublicKey) string {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(host)}, key) + "\n"
+	if err := os.WriteFile(path, []byte(line), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func verifyHostKey(t *testing.T, auth repository.SSHAuthConfig, host string, key ssh.PublicKey) error {
+	t.Helper()
+
+	callback, err := auth.HostKeyCallback()
+	if err != nil {
+		t.Fatalf("host key callback: %v", err)
+	}
+	return callback(host, testHostAddr, key)
+}
+
+func TestKnownHosts(t *testing.T) {
+	key := newHostKey(t)
+	auth := repository.SSHAuthConfig{KnownHostsPath: writeKnownHosts(t, testHost, key)}
+
+	if err := verifyHostKey(t, auth, testHost, key); err != nil {
+		t.Fatalf("known host: expected the key to be accepted: %v", err)
+	}
+
+	err := verifyHostKey(t, auth, "gitlab.example.com:22", key)
+	if err == nil || !strings.Contains(err.Error(), "host not found") {
+		t.Fatalf("unknown host: expected the key to be rejected, found: %v", err)
+	}
+
+	err = verifyHostKey(t, auth, testHost, newHostKey(t))
+	if err == nil || !strings.Contains(err.Error(), "host key mismatch") {
+		t.Fatalf("mismatched key: expected the key to be rejected, found: %v", err)
+	}
+}
+
+func TestPinnedHostKeyFingerprints(t *testing.T) {
+	key, other := newHostKey(t), newHostKey(t)
+	auth := repository.SSHAuthConfig{HostKeyFingerprints: []string{ssh.FingerprintSHA256(other), ssh.FingerprintSHA256(key)}}
+
+	if err := verifyHostKey(t, auth, testHost, key); err != nil {
+		t.Fatalf("pinned key: expected the key to be accepted: %v", err)
+	}
+	if err := verifyHostKey(t, auth, testHost, newHostKey(t)); err == nil {
+		t.Fatalf("unpinned key: expected the key to be rejected")
+	}
+
+	// Keys must pass both checks when both are configured.
+	auth.KnownHostsPath = writeKnownHosts(t, testHost, other)
+	if err := verifyHostKey(t, auth, testHost, key); err == nil {
+		t.Fatalf("pinned key not in known_hosts: expected the key to be rejected")
+	}
+	if err := verifyHostKey(t, auth, testHost, other); err != nil {
+		t.Fatalf("pinned key in known_hosts: expected the key to be accepted: %v", err)
+	}
+}
+
+func TestDefaultHostKeyCallback(t *testing.T) {
+	callback, err := (&repository.SSHAuthConfig{}).HostKeyCallback()
+	if err != nil || callback != nil {
+		t.Fatalf("expected no callback without known_hosts and fingerprints, found: %v", err)
+	}
+
+	auth := repository.SSHAuthConfig{KnownHostsPath: filepath.Join(t.TempDir(), "known_hosts")}
+	if _, err := auth.HostKeyCallback(); err == nil {
+		t.Fatalf("expected an error for a missing known_hosts file")
+	}
+}
+
+func TestKnownHostKeyAlgorithms(t *testing.T) {
+	// The host is known only by an ECDSA key, which servers do not offer by default.
+	hostKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(&hostKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, clientKey, _ := ed25519.GenerateKey(rand.Reader)
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKeyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err = os.WriteFile(privateKeyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	const host = "git.example.com:2222"
+	repo := &repository.GitRepository{Config: repository.Config{
+		Protocol: repository.SSHProtocol,
+		Host:     host,
+		RepoPath: "notes.git",
+		SSHAuth: repository.SSHAuthConfig{
+			User:           "git",
+			PrivateKeyPath: privateKeyPath,
+			KnownHostsPath: writeKnownHosts(t, host, key),
+		},
+	}}
+	method, err := repo.AuthMethod()
+	if err != nil {
+		t.Fatalf("auth method: %v", err)
+	}
+	config, err := method.(gitssh.AuthMethod).ClientConfig()
+	if err != nil {
+		t.Fatalf("client config: %v", err)
+	}
+
+	if len(config.HostKeyAlgorithms) != 1 || config.HostKeyAlgorithms[0] != ssh.KeyAlgoECDSA256 {
+		t.Fatalf("host key algorithms: expected: [%s] found: %v", ssh.KeyAlgoECDSA256, config.HostKeyAlgorithms)
+	}
+	if err = config.HostKeyCallback(host, testHostAddr, key); err != nil {
+		t.Fatalf("known host: expected the key to be accepted: %v", err)
+	}
+}
@@ -2,17 +2,42 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"github.com/cicovic-andrija/anduril/service"
 )
 
 // Package errors.
 var (
-	ErrNotInitialized   = errors.New("repository not initialized")
-	ErrInvalidProtocol  = errors.New("invalid protocol, allowed values are 'https' and 'ssh'")
-	ErrAuthParamMissing = errors.New("missing authentication parameter")
+	ErrNotInitialized         = errors.New("repository not initialized")
+	ErrInvalidProtocol        = errors.New("invalid protocol, allowed values are 'https', 'ssh', 'file' and 'local'")
+	ErrAuthParamMissing       = errors.New("missing authentication parameter")
+	ErrRelativePath           = errors.New("path of a local repository must be absolute")
+	ErrInvalidAuthMethod      = errors.New("invalid HTTP authentication method, allowed values are 'basic' and 'token'")
+	ErrAmbiguousSecret        = errors.New("more than one source of the secret configured")
+	ErrInvalidSyncStrategy    = errors.New("invalid sync strategy, allowed values are 'pull' and 'reset'")
+	ErrInvalidCloneDepth      = errors.New("clone depth cannot be negative")
+	ErrSparseCheckoutStrategy = errors.New("sparse checkout requires the 'reset' sync strategy")
+	ErrSparseCheckoutPath     = errors.New("sparse checkout requires a relative content path")
+	ErrUnsignedCommit         = errors.New("commit is not signed")
+	ErrUntrustedSignature     = errors.New("commit is not signed with a trusted key")
+	ErrRevisionNotFound       = errors.New("revision not found in history")
+	ErrFileNotFound           = errors.New("file not found in revision")
 )
 
+// New returns a repository of the type determined by the protocol in the configuration,
+// or an error value if the configuration is not valid.
+func New(config Config) (Repository, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if config.Protocol == FileProtocol {
+		return &DirectoryRepository{Config: config}, nil
+	}
+	return &GitRepository{Config: config}, nil
+}
+
 // Repository is a local set of files managed by a version control system.
 type Repository interface {
 	// Root returns the absolute path of repository's root directory on the local file system.
@@ -36,3 +61,29 @@ type Repository interface {
 	// LatestRevisionID returns an ID value of the repository's latest revision.
 	LatestRevisionID() string
 }
+
+// History is implemented by repositories which keep the history of their revisions.
+// Revisions are identified by their ID or a prefix of the ID, and content files by
+// their path relative to the content directory.
+type History interface {
+	// ExportRevision writes the content files of a past revision to dir, and returns the full
+	// ID of the revision, or ErrRevisionNotFound if the revision is not in the history of the
+	// latest revision.
+	ExportRevision(id string, dir string) (string, error)
+
+	// FileHistory returns at most limit (0 for no limit) revisions which changed the content file,
+	// newest first.
+	FileHistory(name string, limit int) ([]FileRevision, error)
+
+	// FileContent returns the content of the content file in a past revision, or ErrFileNotFound
+	// if the file does not exist in that revision.
+	FileContent(id string, name string) ([]byte, error)
+}
+
+// FileRevision is a revision which changed a content file.
+type FileRevision struct {
+	ID      string
+	Author  string
+	Time    time.Time
+	Message string
+}
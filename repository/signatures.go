@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+)
+
+// Verification of commit signatures. Commits can be signed either with OpenPGP keys,
+// or with SSH keys (git config gpg.format ssh).
+
+const (
+	pgpSignatureHeader = "-----BEGIN PGP SIGNATURE-----"
+	sshSignatureHeader = "-----BEGIN SSH SIGNATURE-----"
+	sshSignatureFooter = "-----END SSH SIGNATURE-----"
+	sshSignatureMagic  = "SSHSIG"
+	sshGitNamespace    = "git"
+)
+
+// commitVerifier verifies commit signatures against the trusted keys.
+type commitVerifier struct {
+	pgpKeyRing string
+	sshKeys    []ssh.PublicKey
+}
+
+// newCommitVerifier loads the trusted keys, or returns nil if no keys are configured.
+func (c *Config) newCommitVerifier() (*commitVerifier, error) {
+	if c.TrustedPGPKeyRing == "" && len(c.TrustedSSHKeys) == 0 {
+		return nil, nil
+	}
+
+	verifier := &commitVerifier{}
+	if c.TrustedPGPKeyRing != "" {
+		keyRing, err := os.ReadFile(c.TrustedPGPKeyRing)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trusted OpenPGP keyring: %v", err)
+		}
+		verifier.pgpKeyRing = string(keyRing)
+	}
+
+	keys, err := parseSSHSigningKeys(c.TrustedSSHKeys)
+	if err != nil {
+		return nil, err
+	}
+	verifier.sshKeys = keys
+
+	return verifier, nil
+}
+
+// verifyCommit verifies the signature of the commit, if signature verification is configured.
+func (r *GitRepository) verifyCommit(repo *git.Repository, hash plumbing.Hash) error {
+	if r.verifier == nil {
+		return nil
+	}
+
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return fmt.Errorf("verify commit %s: failed to obtain commit: %v", hash, err)
+	}
+
+	signer, err := r.verifier.verify(commit)
+	if err != nil {
+		r.trace(
+			"rejected commit %s by %s <%s> (%q): %v",
+			hash,
+			commit.Author.Name,
+			commit.Author.Email,
+			strings.TrimSpace(commit.Message),
+			err,
+		)
+		return fmt.Errorf("verify commit %s: %w", hash, err)
+	}
+
+	r.trace("commit %s signed by trusted key %s", hash, signer)
+	return nil
+}
+
+// isRejectedCommit reports whether the error was caused by a commit without a trusted signature.
+func isRejectedCommit(err error) bool {
+	return errors.Is(err, ErrUnsignedCommit) || errors.Is(err, ErrUntrustedSignature)
+}
+
+// verify verifies the commit signature, and returns a description of the key which made it.
+func (v *commitVerifier) verify(commit *object.Commit) (string, error) {
+	signature := strings.TrimSpace(commit.PGPSignature)
+	switch {
+	case signature == "":
+		return "", ErrUnsignedCommit
+	case strings.HasPrefix(signature, pgpSignatureHeader):
+		if v.pgpKeyRing == "" {
+			return "", ErrUntrustedSignature
+		}
+		entity, err := commit.Verify(v.pgpKeyRing)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrUntrustedSignature, err)
+		}
+		return fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint), nil
+	case strings.HasPrefix(signature, sshSignatureHeader):
+		payload := &plumbing.MemoryObject{}
+		if err := commit.EncodeWithoutSignature(payload); err != nil {
+			return "", err
+		}
+		reader, err := payload.Reader()
+		if err != nil {
+			return "", err
+		}
+		message, err := io.ReadAll(reader)
+		if err != nil {
+			return "", err
+		}
+		key, err := verifySSHSignature(signature, message, v.sshKeys)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrUntrustedSignature, err)
+		}
+		return ssh.FingerprintSHA256(key), nil
+	default:
+		return "", fmt.Errorf("%w: unknown signature format", ErrUntrustedSignature)
+	}
+}
+
+// verifySSHSignature verifies an armored SSH signature of the message made in the git namespace,
+// as specified by the SSHSIG format of OpenSSH, and returns the trusted key which made it.
+func verifySSHSignature(armored string, message []byte, trusted []ssh.PublicKey) (ssh.PublicKey, error) {
+	encoded := strings.TrimSuffix(strings.TrimPrefix(armored, sshSignatureHeader), sshSignatureFooter)
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %v", err)
+	}
+	if !bytes.HasPrefix(blob, []byte(sshSignatureMagic)) {
+		return nil, fmt.Errorf("malformed signature: missing preamble")
+	}
+
+	var sig struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	if err := ssh.Unmarshal(blob[len(sshSignatureMagic):], &sig); err != nil {
+		return nil, fmt.Errorf("malformed signature: %v", err)
+	}
+	if sig.Version != 1 {
+		return nil, fmt.Errorf("unsupported signature version %d", sig.Version)
+	}
+	if sig.Namespace != sshGitNamespace {
+		return nil, fmt.Errorf("signature namespace is %q instead of %q", sig.Namespace, sshGitNamespace)
+	}
+
+	key, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature key: %v", err)
+	}
+	trustedKey := false
+	for _, candidate := range trusted {
+		if bytes.Equal(candidate.Marshal(), key.Marshal()) {
+			trustedKey = true
+			break
+		}
+	}
+	if !trustedKey {
+		return nil, fmt.Errorf("signed with untrusted key %s", ssh.FingerprintSHA256(key))
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %q", sig.HashAlgorithm)
+	}
+	h.Write(message)
+
+	signedData := append([]byte(sshSignatureMagic), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{sig.Namespace, sig.Reserved, sig.HashAlgorithm, h.Sum(nil)})...)
+
+	signature := &ssh.Signature{}
+	if err := ssh.Unmarshal(sig.Signature, signature); err != nil {
+		return nil, fmt.Errorf("malformed signature: %v", err)
+	}
+	if err := key.Verify(signedData, signature); err != nil {
+		return nil, fmt.Errorf("signature by %s does not match the commit: %v", ssh.FingerprintSHA256(key), err)
+	}
+	return key, nil
+}
+
+func parseSSHSigningKeys(authorizedKeys []string) ([]ssh.PublicKey, error) {
+	keys := make([]ssh.PublicKey, 0, len(authorizedKeys))
+	for _, authorizedKey := range authorizedKeys {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted SSH signing key %q: %v", authorizedKey, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
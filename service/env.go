@@ -58,10 +58,6 @@ func ReadEnvironment() (*Environment, error) {
 		return nil, fmt.Errorf("invalid argument: %v", err)
 	}
 
-	if _, err := exec.LookPath(MarkdownHTMLConverter); err != nil {
-		return nil, fmt.Errorf("dependency not found on the system: %s", MarkdownHTMLConverter)
-	}
-
 	if exists, _ := fs.DirectoryExists(env.DataDirectoryPath()); !exists {
 		return env, fmt.Errorf("directory not found: %s", env.DataDirectoryPath())
 	}
@@ -69,11 +65,29 @@ func ReadEnvironment() (*Environment, error) {
 	return env, nil
 }
 
+// NewEnvironment returns the environment of a program which runs in the working directory wd,
+// instead of the directory of the executable, and is not configured from the command line
+// (e.g. a test).
+func NewEnvironment(wd string) *Environment {
+	return &Environment{
+		pid: os.Getpid(),
+		wd:  wd,
+	}
+}
+
+// CheckDependency returns an error if the external program is not found on the system.
+func CheckDependency(program string) error {
+	if _, err := exec.LookPath(program); err != nil {
+		return fmt.Errorf("dependency not found on the system: %s", program)
+	}
+	return nil
+}
+
 func (env *Environment) Initialize() error {
 	for _, directory := range []string{
 		env.WorkDirectoryPath(),
 		env.LogsDirectoryPath(),
-		env.RepositoryWorkingDirectory(),
+		env.SourcesWorkingDirectory(),
 		env.CompiledWorkDirectory(),
 	} {
 		if err := fs.MkdirIfNotExists(directory); err != nil {
@@ -121,10 +135,28 @@ func (env *Environment) AssetsDataDirectory() string {
 	return filepath.Join(env.DataDirectoryPath(), "assets")
 }
 
-func (env *Environment) RepositoryWorkingDirectory() string {
+// LegacyRepositoryWorkingDirectory is the working directory of the single repository
+// of versions which did not support multiple repositories.
+func (env *Environment) LegacyRepositoryWorkingDirectory() string {
 	return filepath.Join(env.WorkDirectoryPath(), "repository")
 }
 
+func (env *Environment) SourcesWorkingDirectory() string {
+	return filepath.Join(env.WorkDirectoryPath(), "sources")
+}
+
+func (env *Environment) SourceWorkingDirectory(name string) string {
+	return filepath.Join(env.SourcesWorkingDirectory(), name)
+}
+
+func (env *Environment) RevisionPinPath() string {
+	return filepath.Join(env.WorkDirectoryPath(), "revision-pin")
+}
+
+func (env *Environment) RevisionStatePath() string {
+	return filepath.Join(env.WorkDirectoryPath(), "revisions.json")
+}
+
 func (env *Environment) CompiledWorkDirectory() string {
 	return filepath.Join(env.WorkDirectoryPath(), "compiled")
 }
@@ -58,6 +58,9 @@ func parseOptions() (opt options) {
 func main() {
 	options := parseOptions()
 	template := openTemplate(options.templatePath)
+	if _, err := template.MigrateLegacyRepository(); err != nil {
+		die(err.Error())
+	}
 	replaceValues(template, options.profile)
 	saveEncrypted(template, options.outPath, options.password, options.salt)
 	if options.decrypt {
@@ -73,14 +76,22 @@ func replaceValues(template *anduril.Config, profile string) {
 		template.HTTPS.Network.TLSCertPath = "tlspublic.crt"
 		template.HTTPS.Network.TLSKeyPath = "tlsprivate.key"
 		template.HTTPS.AllowOnlyGETRequests = false
-		template.Repository.SSHAuth.PrivateKeyPath = "gitprivate.key"
-		template.Repository.SSHAuth.PrivateKeyPassword = ""
+		for i := range template.Repositories {
+			template.Repositories[i].Repository.SSHAuth.PrivateKeyPath = "gitprivate.key"
+			template.Repositories[i].Repository.SSHAuth.PrivateKeyPassword = ""
+		}
+		template.Settings.SiteURL = "https://localhost:8080"
 		template.Settings.RepositorySyncPeriod = "10s"
 		template.Settings.StaleFileCleanupPeriod = "1h"
+		template.Settings.ReloadTemplates = true
 	case ProdProfile:
+		if template.Settings.SiteURL == "" {
+			die("settings.site_url must be set in the template for the prod profile")
+		}
 		template.HTTPS.Network.IPAcceptHost = "any"
 		template.HTTPS.Network.TCPPort = 443
 		template.HTTPS.AllowOnlyGETRequests = true
+		template.Settings.ReloadTemplates = false
 	}
 }
 
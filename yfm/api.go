@@ -17,3 +17,8 @@ var (
 func Parse(r io.Reader, v interface{}) error {
 	return newParser(r).parse(v)
 }
+
+// Body returns the contents of the document that follow the YAML Front Matter block.
+func Body(r io.Reader) ([]byte, error) {
+	return newParser(r).body()
+}
@@ -28,6 +28,32 @@ func newParser(r io.Reader) *parser {
 }
 
 func (p *parser) parse(v interface{}) error {
+	if err := p.scan(); err != nil {
+		return err
+	}
+
+	if err := yaml.Unmarshal(p.collected.Bytes()[p.start:p.end], v); err != nil {
+		return fmt.Errorf("failed to decode input: %v", err)
+	}
+
+	return nil
+}
+
+func (p *parser) body() ([]byte, error) {
+	if err := p.scan(); err != nil {
+		return nil, err
+	}
+
+	// Everything up to and including the closing delimiter has already been consumed.
+	body, err := io.ReadAll(p.reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %v", err)
+	}
+
+	return body, nil
+}
+
+func (p *parser) scan() error {
 	for {
 		line, eof, err := p.readLine()
 		if err != nil || eof {
@@ -59,10 +85,6 @@ func (p *parser) parse(v interface{}) error {
 		p.read = read_tmp
 		p.end = p.read
 
-		if err := yaml.Unmarshal(p.collected.Bytes()[p.start:p.end], v); err != nil {
-			return fmt.Errorf("failed to decode input: %v", err)
-		}
-
 		return nil
 	}
 }
@@ -61,3 +61,29 @@ modified: '2023-03-03T17:34:50.396Z'
 	}
 	t.Logf("number of tags found: %d", len(metadata.Tags))
 }
+
+func TestBody(t *testing.T) {
+	input := `---
+title: SSI Dry Suit
+tags: [diving]
+---
+# Dry Suit
+
+Body text.
+`
+	expectedBody := "# Dry Suit\n\nBody text.\n"
+	body, err := yfm.Body(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("reading body failed: %v", err)
+	}
+	if string(body) != expectedBody {
+		t.Fatalf("body: expected: %q found: %q", expectedBody, string(body))
+	}
+}
+
+func TestBodyWithoutFrontMatter(t *testing.T) {
+	_, err := yfm.Body(strings.NewReader("# Dry Suit\n"))
+	if err != yfm.ErrNotFound {
+		t.Fatalf("error: expected: %v found: %v", yfm.ErrNotFound, err)
+	}
+}